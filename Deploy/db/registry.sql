@@ -111,7 +111,23 @@ create table replication_policy (
  enabled tinyint(1) NOT NULL DEFAULT 1,
  description text,
  cron_str varchar(256),
+ direction varchar(16) NOT NULL DEFAULT 'push',
  start_time timestamp NULL,
+ last_run_time timestamp NULL,
+ next_run_time timestamp NULL,
+ filters text,
+ /* the max number of jobs of the policy running at the same time, 0 means unlimited */
+ max_concurrent_jobs int NOT NULL DEFAULT 0,
+ /* the project on the target, the same name as the local project is used if it's empty */
+ target_project varchar(256),
+ /* prepended to the names of the repositories on the target */
+ target_repo_prefix varchar(256),
+ /* 1 means the tags which no longer exist at the source are pruned from the target */
+ mirror tinyint(1) NOT NULL DEFAULT 0,
+ /* the max percentage of the tags on the target which can be pruned at once, 0 means the default */
+ mirror_threshold int NOT NULL DEFAULT 0,
+ /* how the tags existing on the target with different digests are handled: overwrite, skip or fail */
+ conflict_mode varchar(16) NOT NULL DEFAULT 'overwrite',
  creation_time timestamp default CURRENT_TIMESTAMP,
  update_time timestamp default CURRENT_TIMESTAMP on update CURRENT_TIMESTAMP,
  PRIMARY KEY (id)
@@ -129,6 +145,12 @@ create table replication_target (
  1 means it's a regulart registry
  */
  target_type tinyint(1) NOT NULL DEFAULT 0,
+ /* KB per second, 0 means unlimited */
+ bandwidth_limit int NOT NULL DEFAULT 0,
+ /* the periods of a day during which jobs for the target can run, e.g. 22:00-06:00,12:00-13:00 */
+ time_windows varchar(256),
+ /* the max number of jobs for the target running at the same time, 0 means unlimited */
+ max_concurrent_jobs int NOT NULL DEFAULT 0,
  creation_time timestamp default CURRENT_TIMESTAMP,
  update_time timestamp default CURRENT_TIMESTAMP on update CURRENT_TIMESTAMP,
  PRIMARY KEY (id)
@@ -138,15 +160,95 @@ create table replication_job (
  id int NOT NULL AUTO_INCREMENT,
  status varchar(64) NOT NULL,
  policy_id int NOT NULL,
+ execution_id int NOT NULL DEFAULT 0,
  repository varchar(256) NOT NULL,
  operation  varchar(64) NOT NULL,
  tags   varchar(16384),
+ priority int NOT NULL DEFAULT 0,
+ lease_owner varchar(256),
+ lease_expire_time timestamp NULL,
+ stop_requested tinyint(1) NOT NULL DEFAULT 0,
+ retry_count int NOT NULL DEFAULT 0,
+ next_retry_time timestamp NULL,
+ progress varchar(1024),
+ checkpoint text,
+ parameters text,
  creation_time timestamp default CURRENT_TIMESTAMP,
  update_time timestamp default CURRENT_TIMESTAMP on update CURRENT_TIMESTAMP,
  PRIMARY KEY (id),
+ INDEX policy (policy_id),
+ INDEX execution (execution_id),
+ INDEX queue (status, priority, id)
+ );
+
+create table replication_execution (
+ id int NOT NULL AUTO_INCREMENT,
+ policy_id int NOT NULL,
+ trigger_type varchar(64) NOT NULL,
+ creation_time timestamp default CURRENT_TIMESTAMP,
+ PRIMARY KEY (id),
  INDEX policy (policy_id)
  );
+
+create table replication_drift (
+ id int NOT NULL AUTO_INCREMENT,
+ job_id int NOT NULL,
+ policy_id int NOT NULL,
+ repository varchar(256) NOT NULL,
+ tag varchar(128) NOT NULL,
+ kind varchar(16) NOT NULL,
+ src_digest varchar(128),
+ dst_digest varchar(128),
+ creation_time timestamp default CURRENT_TIMESTAMP,
+ PRIMARY KEY (id),
+ INDEX job (job_id)
+ );
  
+create table replication_job_result (
+ id int NOT NULL AUTO_INCREMENT,
+ job_id int NOT NULL,
+ repository varchar(255) NOT NULL,
+ tag varchar(128) NOT NULL,
+ result varchar(16) NOT NULL,
+ src_digest varchar(128),
+ dst_digest varchar(128),
+ creation_time timestamp default CURRENT_TIMESTAMP,
+ PRIMARY KEY (id),
+ UNIQUE (job_id, repository, tag)
+ );
+ 
+/* the plans made by the plan jobs, i.e. what the replication of the policies would do */
+create table replication_plan (
+ id int NOT NULL AUTO_INCREMENT,
+ job_id int NOT NULL,
+ policy_id int NOT NULL,
+ plan mediumtext NOT NULL,
+ creation_time timestamp default CURRENT_TIMESTAMP,
+ PRIMARY KEY (id),
+ UNIQUE (job_id)
+ );
+
+/* the interrupted blob upload sessions of the jobs, they are resumed when the jobs are retried */
+create table replication_job_upload (
+ id int NOT NULL AUTO_INCREMENT,
+ job_id int NOT NULL,
+ digest varchar(128) NOT NULL,
+ location varchar(2048) NOT NULL,
+ uuid varchar(64),
+ upload_offset bigint NOT NULL DEFAULT 0,
+ creation_time timestamp default CURRENT_TIMESTAMP,
+ PRIMARY KEY (id),
+ UNIQUE (job_id, digest)
+ );
+
+/* the leases of the periodical tasks of job service, a task is performed by the node holding its lease */
+create table job_lease (
+ name varchar(64) NOT NULL,
+ owner varchar(256) NOT NULL,
+ expire_time timestamp NULL,
+ PRIMARY KEY (name)
+ );
+
 create table properties (
  k varchar(64) NOT NULL,
  v varchar(128) NOT NULL,
@@ -157,4 +259,4 @@ CREATE TABLE IF NOT EXISTS `alembic_version` (
     `version_num` varchar(32) NOT NULL
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-insert into alembic_version values ('0.3.0');
+insert into alembic_version values ('0.4.0');
@@ -0,0 +1,94 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vmware/harbor/api"
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/job"
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/log"
+)
+
+// Job handles /api/jobs and /api/jobs/kinds, it submits and lists jobs of all the kinds
+// registered in the job service.
+type Job struct {
+	api.BaseAPI
+}
+
+// JobReq holds informations of request for /api/jobs
+type JobReq struct {
+	Kind       string          `json:"kind"`
+	PolicyID   int64           `json:"policy_id"`
+	Repo       string          `json:"repository"`
+	TagList    []string        `json:"tags"`
+	Priority   int             `json:"priority"`
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// Post puts a job of the kind into the queue and returns its ID
+func (j *Job) Post() {
+	var data JobReq
+	j.DecodeJSONReq(&data)
+	if job.GetKind(data.Kind) == nil {
+		j.RenderError(http.StatusBadRequest, "Unsupported kind: "+data.Kind)
+		return
+	}
+	rj := models.RepJob{
+		PolicyID:   data.PolicyID,
+		Repository: data.Repo,
+		Operation:  data.Kind,
+		TagList:    data.TagList,
+		Priority:   data.Priority,
+	}
+	if len(data.Parameters) > 0 {
+		rj.Parameters = string(data.Parameters)
+	}
+	id, err := job.SubmitJob(rj)
+	if err != nil {
+		log.Errorf("Failed to submit job, error: %v", err)
+		j.RenderError(http.StatusBadRequest, err.Error())
+		return
+	}
+	j.Data["json"] = map[string]int64{"id": id}
+	j.ServeJSON()
+}
+
+// Get lists the jobs, which can be filtered by kind and status
+func (j *Job) Get() {
+	limit, err := j.GetInt("page_size", 0)
+	if err != nil || limit < 0 {
+		j.RenderError(http.StatusBadRequest, "Invalid page_size")
+		return
+	}
+	jobs, err := dao.FilterJobs(j.GetString("kind"), j.GetString("status"), limit)
+	if err != nil {
+		log.Errorf("Failed to filter jobs, error: %v", err)
+		j.RenderError(http.StatusInternalServerError, "Failed to filter jobs")
+		return
+	}
+	j.Data["json"] = jobs
+	j.ServeJSON()
+}
+
+// Kinds returns the kinds of jobs registered in the job service
+func (j *Job) Kinds() {
+	j.Data["json"] = job.Kinds()
+	j.ServeJSON()
+}
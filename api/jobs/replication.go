@@ -16,17 +16,16 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
-	"net/http/httputil"
+	"os"
 	"strconv"
+	"time"
 
 	"github.com/vmware/harbor/api"
 	"github.com/vmware/harbor/dao"
 	"github.com/vmware/harbor/job"
-	"github.com/vmware/harbor/job/config"
 	"github.com/vmware/harbor/job/utils"
 	"github.com/vmware/harbor/models"
 	"github.com/vmware/harbor/utils/log"
@@ -44,6 +43,8 @@ type ReplicationReq struct {
 	Repo      string   `json:"repository"`
 	Operation string   `json:"operation"`
 	TagList   []string `json:"tags"`
+	Priority  int      `json:"priority"`
+	Trigger   string   `json:"trigger"`
 }
 
 // Post creates replication jobs according to the policy.
@@ -62,53 +63,73 @@ func (rj *ReplicationJob) Post() {
 		rj.RenderError(http.StatusNotFound, fmt.Sprintf("Policy not found, id: %d", data.PolicyID))
 		return
 	}
-	if len(data.Repo) == 0 { // sync all repositories
-		repoList, err := getRepoList(p.ProjectID)
-		if err != nil {
-			log.Errorf("Failed to get repository list, project id: %d, error: %v", p.ProjectID, err)
+	if len(data.Trigger) == 0 {
+		data.Trigger = models.RepTriggerManual
+	}
+	verify := data.Operation == models.RepOpVerify || data.Operation == models.RepOpRepair ||
+		data.Operation == models.RepOpPrune || data.Operation == models.RepOpPlan
+	var executionID int64
+	if !verify && len(data.Repo) == 0 { // sync all repositories
+		if executionID, err = job.SyncPolicy(p, data.Trigger); err != nil {
 			rj.RenderError(http.StatusInternalServerError, err.Error())
 			return
 		}
-		log.Debugf("repo list: %v", repoList)
-		for _, repo := range repoList {
-			err := rj.addJob(repo, data.PolicyID, models.RepOpTransfer)
-			if err != nil {
-				log.Errorf("Failed to insert job record, error: %v", err)
-				rj.RenderError(http.StatusInternalServerError, err.Error())
-				return
-			}
+	} else { // verify or plan the whole policy or sync a single repository
+		if executionID, err = job.AddRepExecution(data.PolicyID, data.Trigger); err != nil {
+			log.Errorf("Failed to insert execution record, error: %v", err)
+			rj.RenderError(http.StatusInternalServerError, err.Error())
+			return
 		}
-	} else { // sync a single repository
-		var op string
-		if len(data.Operation) > 0 {
-			op = data.Operation
-		} else {
-			op = models.RepOpTransfer
+		j := models.RepJob{
+			PolicyID:    data.PolicyID,
+			ExecutionID: executionID,
+			Operation:   data.Operation,
+			Priority:    data.Priority,
 		}
-		err := rj.addJob(data.Repo, data.PolicyID, op, data.TagList...)
-		if err != nil {
+		if !verify {
+			j.Repository = data.Repo
+			j.TagList = data.TagList
+			if len(j.Operation) == 0 {
+				j.Operation = models.RepOpTransfer
+			}
+		}
+		if _, err := job.AddRepJob(j); err != nil {
 			log.Errorf("Failed to insert job record, error: %v", err)
 			rj.RenderError(http.StatusInternalServerError, err.Error())
 			return
 		}
 	}
+	rj.Data["json"] = map[string]int64{"execution_id": executionID}
+	rj.ServeJSON()
 }
 
-func (rj *ReplicationJob) addJob(repo string, policyID int64, operation string, tags ...string) error {
-	j := models.RepJob{
-		Repository: repo,
-		PolicyID:   policyID,
-		Operation:  operation,
-		TagList:    tags,
+// RepCleanupReq holds informations of request for /api/jobs/replication/cleanup,
+// the configured retention is used for the fields which are not set, 0 means no limit
+type RepCleanupReq struct {
+	RetentionDays  *int `json:"retention_days"`
+	RetentionCount *int `json:"retention_count"`
+}
+
+// Cleanup removes the ended jobs out of retention together with their log files
+// and returns what was removed
+func (rj *ReplicationJob) Cleanup() {
+	var data RepCleanupReq
+	if len(rj.Ctx.Input.CopyBody(1<<10)) > 0 {
+		rj.DecodeJSONReq(&data)
 	}
-	log.Debugf("Creating job for repo: %s, policy: %d", repo, policyID)
-	id, err := dao.AddRepJob(j)
+	days, count, err := job.Retention(data.RetentionDays, data.RetentionCount)
 	if err != nil {
-		return err
+		rj.RenderError(http.StatusBadRequest, "Invalid retention")
+		return
 	}
-	log.Debugf("Send job to scheduler, job id: %d", id)
-	job.Schedule(id)
-	return nil
+	result, err := job.CleanupJobs(days, count)
+	if err != nil {
+		log.Errorf("Failed to clean up jobs, error: %v", err)
+		rj.RenderError(http.StatusInternalServerError, "Failed to clean up jobs")
+		return
+	}
+	rj.Data["json"] = result
+	rj.ServeJSON()
 }
 
 // RepActionReq holds informations of request for /api/replicationJobs/actions
@@ -137,10 +158,78 @@ func (rj *ReplicationJob) HandleAction() {
 	for _, j := range jobs {
 		jobIDList = append(jobIDList, j.ID)
 	}
+	// the jobs may be running on other nodes, the request is recorded in DB and
+	// the nodes owning the jobs will stop them
+	if err := dao.RequestStopRepJobs(jobIDList...); err != nil {
+		log.Errorf("Failed to request jobs to stop, error: %v", err)
+		rj.RenderError(http.StatusInternalServerError, "Faild to request jobs to stop")
+		return
+	}
 	job.WorkerPool.StopJobs(jobIDList)
 }
 
-// GetLog gets logs of the job
+// RepJobActionReq holds informations of request for /api/jobs/replication/:id/actions
+type RepJobActionReq struct {
+	Action string `json:"action"`
+}
+
+// HandleJobAction supports the operations to a single job: "stop" stops a running job, "cancel" cancels
+// a job waiting in the queue and "rerun" puts a failed, stopped or canceled job back to the queue.
+func (rj *ReplicationJob) HandleJobAction() {
+	jobID, err := strconv.ParseInt(rj.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil || jobID <= 0 {
+		rj.RenderError(http.StatusBadRequest, "Invalid job id")
+		return
+	}
+	var data RepJobActionReq
+	rj.DecodeJSONReq(&data)
+
+	j, err := dao.GetRepJob(jobID)
+	if err != nil {
+		log.Errorf("Failed to get job %d, error: %v", jobID, err)
+		rj.RenderError(http.StatusInternalServerError, "Failed to get job")
+		return
+	}
+	if j == nil {
+		rj.RenderError(http.StatusNotFound, fmt.Sprintf("Job not found, id: %d", jobID))
+		return
+	}
+
+	var ok bool
+	switch data.Action {
+	case "stop":
+		if ok, err = dao.StopRepJob(jobID); err == nil && ok {
+			// the job may be running on other nodes, they will stop it on the next heartbeat
+			job.WorkerPool.StopJobs([]int64{jobID})
+		}
+	case "cancel":
+		ok, err = dao.CancelRepJob(jobID)
+	case "rerun":
+		if ok, err = dao.RerunRepJob(jobID); err == nil && ok {
+			job.Schedule(jobID)
+		}
+	default:
+		log.Errorf("Unrecognized action: %s", data.Action)
+		rj.RenderError(http.StatusBadRequest, fmt.Sprintf("Unrecongized action: %s", data.Action))
+		return
+	}
+	if err != nil {
+		log.Errorf("Failed to %s job %d, error: %v", data.Action, jobID, err)
+		rj.RenderError(http.StatusInternalServerError, fmt.Sprintf("Failed to %s job", data.Action))
+		return
+	}
+	if !ok {
+		rj.RenderError(http.StatusConflict, fmt.Sprintf("Job %d is %s, can not %s it", jobID, j.Status, data.Action))
+		return
+	}
+	log.Infof("Action %s is applied to job %d", data.Action, jobID)
+}
+
+// GetLog gets logs of the job. If the parameter "tail" is set, only the last n lines are returned,
+// and if "follow" is true, the new lines are streamed until the job is no longer running or the client
+// disconnects. The whole file is served if neither is set, in which case byte ranges are supported.
+// The logs are read from the LOG_DIR of the node serving the request, while a job writes its logs to the
+// node running it, so when several job service nodes are deployed, LOG_DIR must be a volume shared by them.
 func (rj *ReplicationJob) GetLog() {
 	idStr := rj.Ctx.Input.Param(":id")
 	jid, err := strconv.ParseInt(idStr, 10, 64)
@@ -149,52 +238,101 @@ func (rj *ReplicationJob) GetLog() {
 		rj.RenderError(http.StatusBadRequest, "Invalid job id")
 		return
 	}
+	tail, err := rj.GetInt("tail", 0)
+	if err != nil || tail < 0 {
+		rj.RenderError(http.StatusBadRequest, "Invalid tail")
+		return
+	}
+	follow, err := rj.GetBool("follow", false)
+	if err != nil {
+		rj.RenderError(http.StatusBadRequest, "Invalid follow")
+		return
+	}
+
 	logFile := utils.GetJobLogPath(jid)
-	rj.Ctx.Output.Download(logFile)
-}
+	if tail == 0 && !follow {
+		rj.Ctx.Output.Download(logFile)
+		return
+	}
 
-// calls the api from UI to get repo list
-func getRepoList(projectID int64) ([]string, error) {
-	/*
-		uiUser := os.Getenv("UI_USR")
-		if len(uiUser) == 0 {
-			uiUser = "admin"
+	f, err := os.Open(logFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			rj.RenderError(http.StatusNotFound, "Log file not found")
+			return
 		}
-		uiPwd := os.Getenv("UI_PWD")
-		if len(uiPwd) == 0 {
-			uiPwd = "Harbor12345"
+		log.Errorf("Failed to open log file %s, error: %v", logFile, err)
+		rj.RenderError(http.StatusInternalServerError, "Failed to open log file")
+		return
+	}
+	defer f.Close()
+
+	var offset int64
+	if tail > 0 {
+		if offset, err = utils.TailOffset(f, tail); err != nil {
+			log.Errorf("Failed to read log file %s, error: %v", logFile, err)
+			rj.RenderError(http.StatusInternalServerError, "Failed to read log file")
+			return
 		}
-	*/
-	uiURL := config.LocalUIURL()
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", uiURL+"/api/repositories?project_id="+strconv.Itoa(int(projectID)), nil)
-	if err != nil {
-		log.Errorf("Error when creating request: %v", err)
-		return nil, err
-	}
-	//req.SetBasicAuth(uiUser, uiPwd)
-	req.AddCookie(&http.Cookie{Name: models.UISecretCookie, Value: config.UISecret()})
-	//dump, err := httputil.DumpRequest(req, true)
-	//log.Debugf("req: %q", dump)
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Errorf("Error when calling UI api to get repositories, error: %v", err)
-		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		log.Errorf("Unexpected status code: %d", resp.StatusCode)
-		dump, _ := httputil.DumpResponse(resp, true)
-		log.Debugf("response: %q", dump)
-		return nil, fmt.Errorf("Unexpected status code when getting repository list: %d", resp.StatusCode)
+	if _, err = f.Seek(offset, os.SEEK_SET); err != nil {
+		log.Errorf("Failed to read log file %s, error: %v", logFile, err)
+		rj.RenderError(http.StatusInternalServerError, "Failed to read log file")
+		return
+	}
+
+	rj.Ctx.ResponseWriter.Header().Set(http.CanonicalHeaderKey("Content-Type"), "text/plain")
+	if !follow {
+		if _, err = io.Copy(rj.Ctx.ResponseWriter, f); err != nil {
+			log.Errorf("Failed to write log to response, error: %v", err)
+		}
+		return
+	}
+
+	rj.followLog(jid, f)
+}
+
+// the interval to check the log file for new lines in follow mode
+const followInterval = 1 * time.Second
+
+// followLog writes the log to response and flushes it whenever new lines are written,
+// until the job is no longer running or the client disconnects.
+func (rj *ReplicationJob) followLog(jobID int64, f *os.File) {
+	w := rj.Ctx.ResponseWriter
+	closed := w.CloseNotify()
+	for {
+		n, err := io.Copy(w, f)
+		if err != nil {
+			log.Errorf("Failed to write log to response, error: %v", err)
+			return
+		}
+		if n > 0 {
+			w.Flush()
+		}
+
+		// the job may write its last lines between copying and checking the status
+		if running, err := jobRunning(jobID); err != nil || !running {
+			if n, _ = io.Copy(w, f); n > 0 {
+				w.Flush()
+			}
+			return
+		}
+
+		select {
+		case <-closed:
+			return
+		case <-time.After(followInterval):
+		}
 	}
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// jobRunning returns whether the job is running, a job waiting for retrying writes no log until
+// it's claimed again, perhaps by another node, so it's not regarded as running.
+func jobRunning(jobID int64) (bool, error) {
+	j, err := dao.GetRepJob(jobID)
 	if err != nil {
-		log.Errorf("Failed to read the response body, error: %v", err)
-		return nil, err
+		log.Errorf("Failed to get job %d, error: %v", jobID, err)
+		return false, err
 	}
-	var repoList []string
-	err = json.Unmarshal(body, &repoList)
-	return repoList, err
+	return j != nil && j.Status == models.JobRunning, nil
 }
@@ -20,6 +20,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -56,6 +57,31 @@ func (ra *RepJobAPI) Prepare() {
 
 }
 
+// Get returns the job including its progress and results
+func (ra *RepJobAPI) Get() {
+	if ra.jobID == 0 {
+		ra.CustomAbort(http.StatusBadRequest, "id is nil")
+	}
+
+	job, err := dao.GetRepJob(ra.jobID)
+	if err != nil {
+		log.Errorf("failed to get job %d: %v", ra.jobID, err)
+		ra.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	if job == nil {
+		ra.CustomAbort(http.StatusNotFound, fmt.Sprintf("job %d not found", ra.jobID))
+	}
+
+	if job.Results, err = dao.GetRepJobResults(ra.jobID); err != nil {
+		log.Errorf("failed to get results of job %d: %v", ra.jobID, err)
+		ra.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	ra.Data["json"] = job
+	ra.ServeJSON()
+}
+
 // List filters jobs according to the policy and repository
 func (ra *RepJobAPI) List() {
 	var policyID int64
@@ -138,31 +164,86 @@ func (ra *RepJobAPI) Delete() {
 		ra.CustomAbort(http.StatusBadRequest, fmt.Sprintf("job is %s, can not be deleted", job.Status))
 	}
 
+	if err = dao.DeleteRepJobResultsByJob(ra.jobID); err != nil {
+		log.Errorf("failed to delete results of job %d: %v", ra.jobID, err)
+		ra.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
 	if err = dao.DeleteRepJob(ra.jobID); err != nil {
 		log.Errorf("failed to deleted job %d: %v", ra.jobID, err)
 		ra.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 	}
 }
 
-// GetLog ...
+// Action applies an action to the job: "stop" stops a running job, "cancel" cancels a job waiting
+// in the queue and "rerun" runs a failed, stopped or canceled job again.
+func (ra *RepJobAPI) Action() {
+	if ra.jobID == 0 {
+		ra.CustomAbort(http.StatusBadRequest, "id is nil")
+	}
+
+	req := struct {
+		Action string `json:"action"`
+	}{}
+	ra.DecodeJSONReq(&req)
+	if req.Action != "stop" && req.Action != "cancel" && req.Action != "rerun" {
+		ra.CustomAbort(http.StatusBadRequest, fmt.Sprintf("invalid action: %s", req.Action))
+	}
+
+	code, msg, err := postJobAction(ra.jobID, req.Action)
+	if err != nil {
+		log.Errorf("failed to %s job %d: %v", req.Action, ra.jobID, err)
+		ra.CustomAbort(http.StatusInternalServerError, "")
+	}
+	if code != http.StatusOK {
+		ra.CustomAbort(code, msg)
+	}
+}
+
+// GetLog proxies the request to job service, the parameters "tail" and "follow" and
+// the header "Range" are passed through, and the log is streamed to the client.
 func (ra *RepJobAPI) GetLog() {
 	if ra.jobID == 0 {
 		ra.CustomAbort(http.StatusBadRequest, "id is nil")
 	}
 
-	resp, err := http.Get(buildJobLogURL(strconv.FormatInt(ra.jobID, 10)))
+	logURL := buildJobLogURL(strconv.FormatInt(ra.jobID, 10))
+	query := url.Values{}
+	for _, key := range []string{"tail", "follow"} {
+		if value := ra.GetString(key); len(value) != 0 {
+			query.Set(key, value)
+		}
+	}
+	if len(query) != 0 {
+		logURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest("GET", logURL, nil)
+	if err != nil {
+		log.Errorf("failed to create request for job %d: %v", ra.jobID, err)
+		ra.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+	if r := ra.Ctx.Request.Header.Get(http.CanonicalHeaderKey("Range")); len(r) != 0 {
+		req.Header.Set(http.CanonicalHeaderKey("Range"), r)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Errorf("failed to get log for job %d: %v", ra.jobID, err)
 		ra.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		ra.Ctx.ResponseWriter.Header().Set(http.CanonicalHeaderKey("Content-Length"), resp.Header.Get(http.CanonicalHeaderKey("Content-Length")))
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent {
+		for _, key := range []string{"Content-Length", "Content-Range", "Accept-Ranges"} {
+			if value := resp.Header.Get(http.CanonicalHeaderKey(key)); len(value) != 0 {
+				ra.Ctx.ResponseWriter.Header().Set(http.CanonicalHeaderKey(key), value)
+			}
+		}
 		ra.Ctx.ResponseWriter.Header().Set(http.CanonicalHeaderKey("Content-Type"), "text/plain")
+		ra.Ctx.ResponseWriter.WriteHeader(resp.StatusCode)
 
-		if _, err = io.Copy(ra.Ctx.ResponseWriter, resp.Body); err != nil {
+		if err = copyAndFlush(ra.Ctx.ResponseWriter, resp.Body); err != nil {
 			log.Errorf("failed to write log to response; %v", err)
-			ra.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 		}
 		return
 	}
@@ -176,4 +257,53 @@ func (ra *RepJobAPI) GetLog() {
 	ra.CustomAbort(resp.StatusCode, string(b))
 }
 
+// Cleanup removes the ended jobs out of retention together with their log files and returns
+// what was removed. The retention configured in jobservice is used if it is not set in the request,
+// 0 means no limit.
+func (ra *RepJobAPI) Cleanup() {
+	req := struct {
+		RetentionDays  *int `json:"retention_days"`
+		RetentionCount *int `json:"retention_count"`
+	}{}
+	if len(ra.Ctx.Input.CopyBody(1<<10)) > 0 {
+		ra.DecodeJSONReq(&req)
+	}
+	if (req.RetentionDays != nil && *req.RetentionDays < 0) ||
+		(req.RetentionCount != nil && *req.RetentionCount < 0) {
+		ra.CustomAbort(http.StatusBadRequest, "invalid retention")
+	}
+
+	result, err := cleanupRepJobs(req.RetentionDays, req.RetentionCount)
+	if err != nil {
+		log.Errorf("failed to clean up jobs: %v", err)
+		ra.CustomAbort(http.StatusInternalServerError, "")
+	}
+	ra.Data["json"] = result
+	ra.ServeJSON()
+}
+
+// copyAndFlush copies from src to dst and flushes dst after every write, so that
+// the streamed log reaches the client without being buffered
+func copyAndFlush(dst http.ResponseWriter, src io.Reader) error {
+	flusher, _ := dst.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
 //TODO:add Post handler to call job service API to submit jobs by policy
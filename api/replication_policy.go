@@ -140,7 +140,7 @@ func (pa *RepPolicyAPI) Post() {
 
 	if policy.Enabled == 1 {
 		go func() {
-			if err := TriggerReplication(pid, "", nil, models.RepOpTransfer); err != nil {
+			if err := TriggerReplication(pid, "", nil, models.RepOpTransfer, models.RepTriggerManual); err != nil {
 				log.Errorf("failed to trigger replication of %d: %v", pid, err)
 			} else {
 				log.Infof("replication of %d triggered", pid)
@@ -211,6 +211,12 @@ func (pa *RepPolicyAPI) Put() {
 		}
 	}
 
+	// the repositories already replicated would be left under the original names
+	if (policy.TargetProject != originalPolicy.TargetProject ||
+		policy.TargetRepoPrefix != originalPolicy.TargetRepoPrefix) && originalPolicy.Enabled == 1 {
+		pa.CustomAbort(http.StatusBadRequest, "target project and repository prefix of policy can not be modified when the policy is enabled")
+	}
+
 	policy.ID = id
 
 	/*
@@ -272,7 +278,7 @@ func (pa *RepPolicyAPI) Put() {
 
 		if shouldTrigger {
 			go func() {
-				if err := TriggerReplication(id, "", nil, models.RepOpTransfer); err != nil {
+				if err := TriggerReplication(id, "", nil, models.RepOpTransfer, models.RepTriggerManual); err != nil {
 					log.Errorf("failed to trigger replication of %d: %v", id, err)
 				} else {
 					log.Infof("replication of %d triggered", id)
@@ -288,7 +294,7 @@ func (pa *RepPolicyAPI) Put() {
 
 	if policy.Enabled != originalPolicy.Enabled && policy.Enabled == 1 {
 		go func() {
-			if err := TriggerReplication(id, "", nil, models.RepOpTransfer); err != nil {
+			if err := TriggerReplication(id, "", nil, models.RepOpTransfer, models.RepTriggerManual); err != nil {
 				log.Errorf("failed to trigger replication of %d: %v", id, err)
 			} else {
 				log.Infof("replication of %d triggered", id)
@@ -297,6 +303,147 @@ func (pa *RepPolicyAPI) Put() {
 	}
 }
 
+// Plan triggers a job which dry runs the policy, the result can be got from GetPlan
+func (pa *RepPolicyAPI) Plan() {
+	id := pa.GetIDFromURL()
+	policy, err := dao.GetRepPolicy(id)
+	if err != nil {
+		log.Errorf("failed to get policy %d: %v", id, err)
+		pa.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	if policy == nil {
+		pa.CustomAbort(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	}
+
+	if err = TriggerReplication(id, "", nil, models.RepOpPlan, models.RepTriggerManual); err != nil {
+		log.Errorf("failed to trigger plan of %d: %v", id, err)
+		pa.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+}
+
+// GetPlan returns what the last plan job of the policy found the replication would do
+func (pa *RepPolicyAPI) GetPlan() {
+	id := pa.GetIDFromURL()
+	job, err := dao.GetLastRepJobByOperation(id, models.RepOpPlan)
+	if err != nil {
+		log.Errorf("failed to get the last plan job of policy %d: %v", id, err)
+		pa.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	if job == nil {
+		pa.CustomAbort(http.StatusNotFound, "the policy has not been planned")
+	}
+
+	plan, err := dao.GetRepPlanByJob(job.ID)
+	if err != nil {
+		log.Errorf("failed to get the plan made by job %d: %v", job.ID, err)
+		pa.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	pa.Data["json"] = &models.RepPlanReport{
+		Job:  job,
+		Plan: plan,
+	}
+	pa.ServeJSON()
+}
+
+// Verify triggers a job to compare the source and the target of the policy, if the
+// parameter "repair" is true, transfer jobs are created for the differences.
+func (pa *RepPolicyAPI) Verify() {
+	id := pa.GetIDFromURL()
+	policy, err := dao.GetRepPolicy(id)
+	if err != nil {
+		log.Errorf("failed to get policy %d: %v", id, err)
+		pa.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	if policy == nil {
+		pa.CustomAbort(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	}
+
+	repair, err := pa.GetBool("repair", false)
+	if err != nil {
+		pa.CustomAbort(http.StatusBadRequest, "invalid repair")
+	}
+
+	op := models.RepOpVerify
+	if repair {
+		op = models.RepOpRepair
+	}
+
+	if err = TriggerReplication(id, "", nil, op, models.RepTriggerManual); err != nil {
+		log.Errorf("failed to trigger verification of %d: %v", id, err)
+		pa.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+}
+
+// GetDrift returns the differences found by the last verification job of the policy
+func (pa *RepPolicyAPI) GetDrift() {
+	id := pa.GetIDFromURL()
+	job, err := dao.GetLastRepJobByOperation(id, models.RepOpVerify, models.RepOpRepair)
+	if err != nil {
+		log.Errorf("failed to get the last verification job of policy %d: %v", id, err)
+		pa.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	if job == nil {
+		pa.CustomAbort(http.StatusNotFound, "the policy has not been verified")
+	}
+
+	drifts, err := dao.GetRepDriftsByJob(job.ID)
+	if err != nil {
+		log.Errorf("failed to get the differences found by job %d: %v", job.ID, err)
+		pa.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	pa.Data["json"] = &models.RepDriftReport{
+		Job:    job,
+		Drifts: drifts,
+	}
+	pa.ServeJSON()
+}
+
+// ListExecutions returns the executions of the policy from the latest to the earliest
+func (pa *RepPolicyAPI) ListExecutions() {
+	id := pa.GetIDFromURL()
+	num, err := pa.GetInt("page_size", 0)
+	if err != nil || num < 0 {
+		pa.CustomAbort(http.StatusBadRequest, "invalid page_size")
+	}
+
+	executions, err := dao.GetRepExecutionsByPolicy(id, num)
+	if err != nil {
+		log.Errorf("failed to get executions of policy %d: %v", id, err)
+		pa.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	pa.Data["json"] = executions
+	pa.ServeJSON()
+}
+
+// GetExecution returns the execution of the policy with its jobs
+func (pa *RepPolicyAPI) GetExecution() {
+	id := pa.GetIDFromURL()
+	eid, err := strconv.ParseInt(pa.Ctx.Input.Param(":eid"), 10, 64)
+	if err != nil || eid <= 0 {
+		pa.CustomAbort(http.StatusBadRequest, "invalid execution ID")
+	}
+
+	execution, err := dao.GetRepExecution(eid)
+	if err != nil {
+		log.Errorf("failed to get execution %d: %v", eid, err)
+		pa.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	if execution == nil || execution.PolicyID != id {
+		pa.CustomAbort(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	}
+
+	pa.Data["json"] = execution
+	pa.ServeJSON()
+}
+
 type enablementReq struct {
 	Enabled int `json:"enabled"`
 }
@@ -333,7 +480,7 @@ func (pa *RepPolicyAPI) UpdateEnablement() {
 
 	if e.Enabled == 1 {
 		go func() {
-			if err := TriggerReplication(id, "", nil, models.RepOpTransfer); err != nil {
+			if err := TriggerReplication(id, "", nil, models.RepOpTransfer, models.RepTriggerManual); err != nil {
 				log.Errorf("failed to trigger replication of %d: %v", id, err)
 			} else {
 				log.Infof("replication of %d triggered", id)
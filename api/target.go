@@ -16,11 +16,14 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/vmware/harbor/dao"
 	"github.com/vmware/harbor/models"
@@ -116,6 +119,41 @@ func (t *TargetAPI) Ping() {
 		log.Errorf("failed to ping registry %s: %v", registry.Endpoint.String(), err)
 		t.CustomAbort(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 	}
+
+	t.Data["json"] = map[string]int{
+		"type": detectTargetType(registry.Endpoint.String(), getIsInsecure()),
+	}
+	t.ServeJSON()
+}
+
+// the timeout of the request detecting the type of a target
+const detectTimeout = 30 * time.Second
+
+// detectTargetType checks whether the endpoint is a Harbor by requesting its
+// public project API, which a regular registry does not provide
+func detectTargetType(endpoint string, insecure bool) int {
+	client := &http.Client{
+		Transport: registry.GetHTTPTransport(insecure),
+		Timeout:   detectTimeout,
+	}
+
+	resp, err := client.Get(strings.TrimRight(endpoint, "/") + "/api/projects?is_public=1")
+	if err != nil {
+		log.Warningf("failed to detect the type of %s, regard it as a regular registry: %v", endpoint, err)
+		return models.RepTargetTypeRegistry
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.RepTargetTypeRegistry
+	}
+
+	projects := []interface{}{}
+	if err = json.NewDecoder(resp.Body).Decode(&projects); err != nil {
+		return models.RepTargetTypeRegistry
+	}
+
+	return models.RepTargetTypeHarbor
 }
 
 // Get ...
@@ -319,7 +357,8 @@ func newRegistryClient(endpoint string, insecure bool, username, password, scope
 	credential := auth.NewBasicAuthCredential(username, password)
 	authorizer := auth.NewStandardTokenAuthorizer(credential, insecure, scopeType, scopeName, scopeActions...)
 
-	store, err := auth.NewAuthorizerStore(endpoint, insecure, authorizer)
+	// the regular registry may use basic auth rather than token auth
+	store, err := auth.NewAuthorizerStore(endpoint, insecure, authorizer, auth.NewBasicAuthorizer(credential))
 	if err != nil {
 		return nil, err
 	}
@@ -93,19 +93,22 @@ func checkUserExists(name string) int {
 	return 0
 }
 
-// TriggerReplication triggers the replication according to the policy
+// TriggerReplication triggers the replication according to the policy, the trigger is recorded
+// in the execution created for it
 func TriggerReplication(policyID int64, repository string,
-	tags []string, operation string) error {
+	tags []string, operation, trigger string) error {
 	data := struct {
 		PolicyID  int64    `json:"policy_id"`
 		Repo      string   `json:"repository"`
 		Operation string   `json:"operation"`
 		TagList   []string `json:"tags"`
+		Trigger   string   `json:"trigger"`
 	}{
 		PolicyID:  policyID,
 		Repo:      repository,
 		TagList:   tags,
 		Operation: operation,
+		Trigger:   trigger,
 	}
 
 	b, err := json.Marshal(&data)
@@ -161,10 +164,24 @@ func TriggerReplicationByRepository(repository string, tags []string, operation
 	}
 
 	for _, policy := range policies {
-		if policy.Enabled == 0 {
+		// the images pushed to the local project are not replicated by pull-mode policies
+		if policy.Enabled == 0 || policy.Direction == models.RepDirectionPull {
 			continue
 		}
-		if err := TriggerReplication(policy.ID, repository, tags, operation); err != nil {
+		if !policy.MatchRepository(repository) {
+			log.Debugf("repository %s does not match the filters of policy %d, skip", repository, policy.ID)
+			continue
+		}
+		// if no tag is specified, the whole repository is replicated or deleted, the tags
+		// excluded by the filters of the policy are left untouched by the job
+		t := tags
+		if len(tags) > 0 {
+			if t = policy.FilterTags(tags); len(t) == 0 {
+				log.Debugf("tags %v of %s do not match the filters of policy %d, skip", tags, repository, policy.ID)
+				continue
+			}
+		}
+		if err := TriggerReplication(policy.ID, repository, t, operation, models.RepTriggerEvent); err != nil {
 			log.Errorf("failed to trigger replication of policy %d for %s: %v", policy.ID, repository, err)
 		} else {
 			log.Infof("replication of policy %d for %s triggered", policy.ID, repository)
@@ -172,6 +189,77 @@ func TriggerReplicationByRepository(repository string, tags []string, operation
 	}
 }
 
+// cleanupRepJobs asks jobservice to remove the ended jobs out of retention, the retention
+// configured in jobservice is used if days or count is nil
+func cleanupRepJobs(days, count *int) (*models.RepJobCleanup, error) {
+	data := struct {
+		RetentionDays  *int `json:"retention_days,omitempty"`
+		RetentionCount *int `json:"retention_count,omitempty"`
+	}{
+		RetentionDays:  days,
+		RetentionCount: count,
+	}
+
+	b, err := json.Marshal(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	url := buildJobCleanupURL()
+
+	resp, err := http.DefaultClient.Post(url, "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%d %s", resp.StatusCode, string(b))
+	}
+
+	result := &models.RepJobCleanup{}
+	if err = json.Unmarshal(b, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// postJobAction asks jobservice to apply the action to a single job, the status code and the
+// message of the response are returned
+func postJobAction(jobID int64, action string) (int, string, error) {
+	data := struct {
+		Action string `json:"action"`
+	}{
+		Action: action,
+	}
+
+	b, err := json.Marshal(&data)
+	if err != nil {
+		return 0, "", err
+	}
+
+	url := buildJobActionURL(jobID)
+
+	resp, err := http.DefaultClient.Post(url, "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return resp.StatusCode, string(b), nil
+}
+
 func postReplicationAction(policyID int64, acton string) error {
 	data := struct {
 		PolicyID int64  `json:"policy_id"`
@@ -217,6 +305,16 @@ func buildJobLogURL(jobID string) string {
 	return fmt.Sprintf("%s/api/jobs/replication/%s/log", url, jobID)
 }
 
+func buildJobCleanupURL() string {
+	url := getJobServiceURL()
+	return fmt.Sprintf("%s/api/jobs/replication/cleanup", url)
+}
+
+func buildJobActionURL(jobID int64) string {
+	url := getJobServiceURL()
+	return fmt.Sprintf("%s/api/jobs/replication/%d/actions", url, jobID)
+}
+
 func buildReplicationActionURL() string {
 	url := getJobServiceURL()
 	return fmt.Sprintf("%s/api/jobs/replication/actions", url)
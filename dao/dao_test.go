@@ -959,6 +959,9 @@ func TestAddRepPolicy(t *testing.T) {
 		t.Errorf("The data does not match, expected: Name: mypolicy, TargetID: %d, Enabled: 1, Description: whatever;\n result: Name: %s, TargetID: %d, Enabled: %d, Description: %s",
 			targetID, p.Name, p.TargetID, p.Enabled, p.Description)
 	}
+	if p.Direction != models.RepDirectionPush {
+		t.Errorf("Unexpected direction: %s, expected: %s", p.Direction, models.RepDirectionPush)
+	}
 	var tm = time.Now().AddDate(0, 0, -1)
 	if !p.StartTime.After(tm) {
 		t.Errorf("Unexpected start_time: %v", p.StartTime)
@@ -1049,11 +1052,16 @@ func TestEnableRepPolicy(t *testing.T) {
 
 func TestAddRepPolicy2(t *testing.T) {
 	policy2 := models.RepPolicy{
-		ProjectID:   3,
-		Enabled:     0,
-		TargetID:    3,
-		Description: "whatever",
-		Name:        "mypolicy",
+		ProjectID:        3,
+		Enabled:          0,
+		TargetID:         3,
+		Description:      "whatever",
+		Name:             "mypolicy",
+		TargetProject:    "mirror",
+		TargetRepoPrefix: "dev-",
+		Mirror:           1,
+		MirrorThreshold:  20,
+		ConflictMode:     models.ConflictSkip,
 	}
 	policyID2, err := AddRepPolicy(policy2)
 	t.Logf("added policy, id: %d", policyID2)
@@ -1071,6 +1079,15 @@ func TestAddRepPolicy2(t *testing.T) {
 	if p.StartTime.After(tm) {
 		t.Errorf("Unexpected start_time: %v", p.StartTime)
 	}
+	if p.TargetProject != "mirror" || p.TargetRepoPrefix != "dev-" {
+		t.Errorf("Unexpected target project and repository prefix: %s, %s", p.TargetProject, p.TargetRepoPrefix)
+	}
+	if p.Mirror != 1 || p.MirrorThreshold != 20 {
+		t.Errorf("Unexpected mirror settings: %d, %d", p.Mirror, p.MirrorThreshold)
+	}
+	if p.ConflictMode != models.ConflictSkip {
+		t.Errorf("Unexpected conflict mode: %s", p.ConflictMode)
+	}
 }
 
 func TestAddRepJob(t *testing.T) {
@@ -1262,6 +1279,736 @@ func TestGetRepoJobToStop(t *testing.T) {
 	}
 }
 
+func TestClaimRepJob(t *testing.T) {
+	jobs := []models.RepJob{
+		models.RepJob{
+			Repository: "library/claim1",
+			PolicyID:   policyID,
+			Operation:  "transfer",
+		},
+		models.RepJob{
+			Repository: "library/claim2",
+			PolicyID:   policyID,
+			Operation:  "transfer",
+			Priority:   1,
+		},
+		models.RepJob{
+			Repository: "library/claim3",
+			PolicyID:   policyID,
+			Operation:  "transfer",
+		},
+	}
+	var ids []int64
+	for _, j := range jobs {
+		id, err := AddRepJob(j)
+		if err != nil {
+			t.Errorf("Failed to add job: %+v, error: %v", j, err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	defer func() {
+		for _, id := range ids {
+			if err := DeleteRepJob(id); err != nil {
+				t.Errorf("Failed to delete job, id: %d, error: %v", id, err)
+			}
+		}
+	}()
+
+	// the jobs of excluded targets are not claimed
+	j, err := ClaimRepJob("owner", time.Minute, nil, []int64{targetID})
+	if err != nil {
+		t.Errorf("Error occurred in ClaimRepJob: %v", err)
+		return
+	}
+	if j != nil {
+		t.Errorf("Job of excluded target should not be claimed, job: %d", j.ID)
+		return
+	}
+
+	// the job with higher priority first, then FIFO
+	expected := []int64{ids[1], ids[0], ids[2]}
+	for _, id := range expected {
+		j, err := ClaimRepJob("owner", time.Minute, nil, nil)
+		if err != nil {
+			t.Errorf("Error occurred in ClaimRepJob: %v", err)
+			return
+		}
+		if j == nil {
+			t.Errorf("Unable to claim job, expected: %d", id)
+			return
+		}
+		if j.ID != id {
+			t.Errorf("Unexpected job claimed, expected: %d, in fact: %d", id, j.ID)
+			return
+		}
+	}
+
+	j, err = ClaimRepJob("owner", time.Minute, nil, nil)
+	if err != nil {
+		t.Errorf("Error occurred in ClaimRepJob: %v", err)
+		return
+	}
+	if j != nil {
+		t.Errorf("Leased job should not be claimed again, job: %d", j.ID)
+		return
+	}
+
+	if err = ReleaseRepJob(ids[0], "owner"); err != nil {
+		t.Errorf("Error occurred in ReleaseRepJob: %v, id: %d", err, ids[0])
+		return
+	}
+	j, err = ClaimRepJob("owner", time.Minute, nil, nil)
+	if err != nil {
+		t.Errorf("Error occurred in ClaimRepJob: %v", err)
+		return
+	}
+	if j == nil || j.ID != ids[0] {
+		t.Errorf("Released job should be claimed again, expected: %d, in fact: %+v", ids[0], j)
+		return
+	}
+
+	if err = UpdateRepJobRetry(ids[0], 1, time.Minute); err != nil {
+		t.Errorf("Error occurred in UpdateRepJobRetry: %v, id: %d", err, ids[0])
+		return
+	}
+	if err = ReleaseRepJob(ids[0], "owner"); err != nil {
+		t.Errorf("Error occurred in ReleaseRepJob: %v, id: %d", err, ids[0])
+		return
+	}
+	j, err = ClaimRepJob("owner", time.Minute, nil, nil)
+	if err != nil {
+		t.Errorf("Error occurred in ClaimRepJob: %v", err)
+		return
+	}
+	if j != nil {
+		t.Errorf("Retrying job should not be claimed before the next retry time, job: %d", j.ID)
+		return
+	}
+
+	if err = UpdateRepJobRetry(ids[0], 2, 0); err != nil {
+		t.Errorf("Error occurred in UpdateRepJobRetry: %v, id: %d", err, ids[0])
+		return
+	}
+	j, err = ClaimRepJob("owner", time.Minute, nil, nil)
+	if err != nil {
+		t.Errorf("Error occurred in ClaimRepJob: %v", err)
+		return
+	}
+	if j == nil || j.ID != ids[0] {
+		t.Errorf("Retrying job should be claimed after the next retry time, expected: %d, in fact: %+v", ids[0], j)
+		return
+	}
+	if j.Status != models.JobRetrying || j.RetryCount != 2 {
+		t.Errorf("Unexpected status or retry count of job %d: %s, %d", j.ID, j.Status, j.RetryCount)
+	}
+}
+
+func TestUpdateRepJobProgress(t *testing.T) {
+	j := models.RepJob{
+		Repository: "library/progress",
+		PolicyID:   policyID,
+		Operation:  "transfer",
+	}
+	id, err := AddRepJob(j)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", j, err)
+		return
+	}
+	defer DeleteRepJob(id)
+
+	progress := &models.RepJobProgress{
+		TagsTotal:        3,
+		TagsDone:         1,
+		CurrentTag:       "latest",
+		BytesTransferred: 1024,
+	}
+	if err = UpdateRepJobProgress(id, progress); err != nil {
+		t.Errorf("Error occurred in UpdateRepJobProgress: %v, id: %d", err, id)
+		return
+	}
+
+	job, err := GetRepJob(id)
+	if err != nil {
+		t.Errorf("Error occurred in GetRepJob: %v, id: %d", err, id)
+		return
+	}
+	if job.Progress == nil {
+		t.Errorf("The progress of job %d is nil", id)
+		return
+	}
+	if job.Progress.TagsTotal != 3 || job.Progress.TagsDone != 1 ||
+		job.Progress.CurrentTag != "latest" || job.Progress.BytesTransferred != 1024 {
+		t.Errorf("Unexpected progress of job %d: %+v", id, job.Progress)
+	}
+}
+
+func TestRepDrifts(t *testing.T) {
+	j := models.RepJob{
+		Repository: "",
+		PolicyID:   policyID,
+		Operation:  models.RepOpVerify,
+	}
+	id, err := AddRepJob(j)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", j, err)
+		return
+	}
+	defer DeleteRepJob(id)
+
+	job, err := GetLastRepJobByOperation(policyID, models.RepOpVerify, models.RepOpRepair)
+	if err != nil {
+		t.Errorf("Error occurred in GetLastRepJobByOperation: %v, policy id: %d", err, policyID)
+		return
+	}
+	if job == nil || job.ID != id {
+		t.Errorf("Unexpected last verification job: %+v, expected id: %d", job, id)
+		return
+	}
+
+	drifts := []*models.RepDrift{
+		{
+			JobID:      id,
+			PolicyID:   policyID,
+			Repository: "library/drift",
+			Tag:        "v2",
+			Kind:       models.DriftStale,
+			SrcDigest:  "sha256:aaa",
+			DstDigest:  "sha256:bbb",
+		},
+		{
+			JobID:      id,
+			PolicyID:   policyID,
+			Repository: "library/drift",
+			Tag:        "v1",
+			Kind:       models.DriftMissing,
+			SrcDigest:  "sha256:ccc",
+		},
+	}
+	if err = AddRepDrifts(drifts); err != nil {
+		t.Errorf("Error occurred in AddRepDrifts: %v", err)
+		return
+	}
+
+	result, err := GetRepDriftsByJob(id)
+	if err != nil {
+		t.Errorf("Error occurred in GetRepDriftsByJob: %v, job id: %d", err, id)
+		return
+	}
+	if len(result) != 2 {
+		t.Errorf("Unexpected length of drifts, expected: 2, in fact: %d", len(result))
+		return
+	}
+	if result[0].Tag != "v1" || result[0].Kind != models.DriftMissing ||
+		result[1].Tag != "v2" || result[1].DstDigest != "sha256:bbb" {
+		t.Errorf("Unexpected drifts: %+v, %+v", result[0], result[1])
+	}
+}
+
+func TestRepPlan(t *testing.T) {
+	j := models.RepJob{
+		Repository: "",
+		PolicyID:   policyID,
+		Operation:  models.RepOpPlan,
+	}
+	id, err := AddRepJob(j)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", j, err)
+		return
+	}
+	defer DeleteRepJob(id)
+	defer DeleteRepPlanByJob(id)
+
+	plan, err := GetRepPlanByJob(id)
+	if err != nil {
+		t.Errorf("Error occurred in GetRepPlanByJob: %v, job id: %d", err, id)
+		return
+	}
+	if plan != nil {
+		t.Errorf("Unexpected plan of job %d: %+v, expected: nil", id, plan)
+		return
+	}
+
+	p := &models.RepPlan{
+		PolicyID:        policyID,
+		TagsToTransfer:  1,
+		TagsConflicting: 1,
+	}
+	if err = AddRepPlan(id, p); err != nil {
+		t.Errorf("Error occurred in AddRepPlan: %v", err)
+		return
+	}
+
+	plan, err = GetRepPlanByJob(id)
+	if err != nil {
+		t.Errorf("Error occurred in GetRepPlanByJob: %v, job id: %d", err, id)
+		return
+	}
+	if plan == nil || plan.PolicyID != policyID || plan.TagsToTransfer != 1 ||
+		plan.TagsConflicting != 1 {
+		t.Errorf("Unexpected plan of job %d: %+v", id, plan)
+	}
+}
+
+func TestRepJobResults(t *testing.T) {
+	j := models.RepJob{
+		Repository: "library/result",
+		PolicyID:   policyID,
+		Operation:  models.RepOpTransfer,
+	}
+	id, err := AddRepJob(j)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", j, err)
+		return
+	}
+	defer DeleteRepJob(id)
+	defer DeleteRepJobResultsByJob(id)
+
+	results := []*models.RepJobResult{
+		{
+			JobID:      id,
+			Repository: "library/result",
+			Tag:        "v2",
+			Result:     models.TagResultSkipped,
+			SrcDigest:  "sha256:aaa",
+			DstDigest:  "sha256:bbb",
+		},
+		{
+			JobID:      id,
+			Repository: "library/result",
+			Tag:        "v1",
+			Result:     models.TagResultSkipped,
+		},
+		// the result of v1 is replaced
+		{
+			JobID:      id,
+			Repository: "library/result",
+			Tag:        "v1",
+			Result:     models.TagResultConflict,
+			SrcDigest:  "sha256:ccc",
+			DstDigest:  "sha256:ddd",
+		},
+		// the same tag of another repository
+		{
+			JobID:      id,
+			Repository: "library/result2",
+			Tag:        "v1",
+			Result:     models.TagResultSkipped,
+		},
+	}
+	for _, r := range results {
+		if err = AddRepJobResult(r); err != nil {
+			t.Errorf("Error occurred in AddRepJobResult: %v", err)
+			return
+		}
+	}
+
+	result, err := GetRepJobResults(id)
+	if err != nil {
+		t.Errorf("Error occurred in GetRepJobResults: %v, job id: %d", err, id)
+		return
+	}
+	if len(result) != 3 {
+		t.Errorf("Unexpected length of results, expected: 3, in fact: %d", len(result))
+		return
+	}
+	if result[0].Tag != "v1" || result[0].Result != models.TagResultConflict || result[0].DstDigest != "sha256:ddd" ||
+		result[1].Tag != "v2" || result[1].Result != models.TagResultSkipped ||
+		result[2].Repository != "library/result2" || result[2].Tag != "v1" {
+		t.Errorf("Unexpected results: %+v, %+v, %+v", result[0], result[1], result[2])
+	}
+
+	if err = DeleteRepJobResultsByJob(id); err != nil {
+		t.Errorf("Error occurred in DeleteRepJobResultsByJob: %v, job id: %d", err, id)
+		return
+	}
+	if result, err = GetRepJobResults(id); err != nil || len(result) != 0 {
+		t.Errorf("Unexpected results after deletion: %v, error: %v", result, err)
+	}
+}
+
+func TestRepJobUploads(t *testing.T) {
+	j := models.RepJob{
+		Repository: "library/upload",
+		PolicyID:   policyID,
+		Operation:  models.RepOpTransfer,
+	}
+	id, err := AddRepJob(j)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", j, err)
+		return
+	}
+	defer DeleteRepJob(id)
+
+	upload := &models.RepBlobUpload{
+		JobID:    id,
+		Digest:   "sha256:aaa",
+		Location: "http://registry/v2/library/upload/blobs/uploads/uuid",
+		UUID:     "uuid",
+		Offset:   1024,
+	}
+	if err = SaveRepJobUpload(upload); err != nil {
+		t.Errorf("Error occurred in SaveRepJobUpload: %v, id: %d", err, id)
+		return
+	}
+	// the session of the same blob is replaced
+	upload.Offset = 2048
+	if err = SaveRepJobUpload(upload); err != nil {
+		t.Errorf("Error occurred in SaveRepJobUpload: %v, id: %d", err, id)
+		return
+	}
+
+	result, err := GetRepJobUpload(id, "sha256:aaa")
+	if err != nil {
+		t.Errorf("Error occurred in GetRepJobUpload: %v, id: %d", err, id)
+		return
+	}
+	if result == nil || result.UUID != "uuid" || result.Offset != 2048 {
+		t.Errorf("Unexpected upload: %+v", result)
+	}
+
+	if err = DeleteRepJobUpload(id, "sha256:aaa"); err != nil {
+		t.Errorf("Error occurred in DeleteRepJobUpload: %v, id: %d", err, id)
+		return
+	}
+	if result, err = GetRepJobUpload(id, "sha256:aaa"); err != nil || result != nil {
+		t.Errorf("Unexpected upload after deletion: %+v, error: %v", result, err)
+	}
+
+	// the uploads are cleared when the job ends
+	upload.Digest = "sha256:bbb"
+	if err = SaveRepJobUpload(upload); err != nil {
+		t.Errorf("Error occurred in SaveRepJobUpload: %v, id: %d", err, id)
+		return
+	}
+	if err = UpdateRepJobStatus(id, models.JobError); err != nil {
+		t.Errorf("Error occurred in UpdateRepJobStatus: %v, id: %d", err, id)
+		return
+	}
+	if result, err = GetRepJobUpload(id, "sha256:bbb"); err != nil || result != nil {
+		t.Errorf("Unexpected upload after the job ends: %+v, error: %v", result, err)
+	}
+}
+
+func TestUpdateRepJobCheckpoint(t *testing.T) {
+	j := models.RepJob{
+		Repository: "library/checkpoint",
+		PolicyID:   policyID,
+		Operation:  "transfer",
+		TagList:    []string{"v1", "v2", "v3"},
+	}
+	id, err := AddRepJob(j)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", j, err)
+		return
+	}
+	defer DeleteRepJob(id)
+
+	if err = UpdateRepJobCheckpoint(id, []string{"v2", "v3"}); err != nil {
+		t.Errorf("Error occurred in UpdateRepJobCheckpoint: %v, id: %d", err, id)
+		return
+	}
+
+	job, err := GetRepJob(id)
+	if err != nil {
+		t.Errorf("Error occurred in GetRepJob: %v, id: %d", err, id)
+		return
+	}
+	tags, ok, err := job.RemainingTags()
+	if err != nil || !ok || len(tags) != 2 || tags[0] != "v2" || len(job.TagList) != 3 {
+		t.Errorf("Unexpected checkpoint: %s, tags: %v", job.Checkpoint, job.TagList)
+	}
+
+	// no tag is left, which differs from no checkpoint
+	if err = UpdateRepJobCheckpoint(id, nil); err != nil {
+		t.Errorf("Error occurred in UpdateRepJobCheckpoint: %v, id: %d", err, id)
+		return
+	}
+	if job, err = GetRepJob(id); err != nil {
+		t.Errorf("Error occurred in GetRepJob: %v, id: %d", err, id)
+		return
+	}
+	if tags, ok, err = job.RemainingTags(); err != nil || !ok || len(tags) != 0 {
+		t.Errorf("Unexpected checkpoint: %s, error: %v", job.Checkpoint, err)
+	}
+}
+
+func TestRequestStopRepJobs(t *testing.T) {
+	j := models.RepJob{
+		Repository: "library/stop",
+		PolicyID:   policyID,
+		Operation:  "transfer",
+	}
+	id, err := AddRepJob(j)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", j, err)
+		return
+	}
+	defer DeleteRepJob(id)
+
+	claimed, err := ClaimRepJob("node1", time.Minute, nil, nil)
+	if err != nil {
+		t.Errorf("Error occurred in ClaimRepJob: %v", err)
+		return
+	}
+	if claimed == nil || claimed.ID != id {
+		t.Errorf("Unexpected job claimed: %+v, expected: %d", claimed, id)
+		return
+	}
+	if err = RenewRepJobLeases("node1", time.Hour); err != nil {
+		t.Errorf("Error occurred in RenewRepJobLeases: %v", err)
+		return
+	}
+
+	if err = RequestStopRepJobs(id); err != nil {
+		t.Errorf("Error occurred in RequestStopRepJobs: %v", err)
+		return
+	}
+
+	ids, err := GetRepJobIDsToStop("node2")
+	if err != nil {
+		t.Errorf("Error occurred in GetRepJobIDsToStop: %v", err)
+		return
+	}
+	if len(ids) != 0 {
+		t.Errorf("Unexpected jobs to stop for node2: %v", ids)
+		return
+	}
+
+	ids, err = GetRepJobIDsToStop("node1")
+	if err != nil {
+		t.Errorf("Error occurred in GetRepJobIDsToStop: %v", err)
+		return
+	}
+	if len(ids) != 1 || ids[0] != id {
+		t.Errorf("Unexpected jobs to stop for node1: %v, expected: %d", ids, id)
+		return
+	}
+
+	if err = ReleaseRepJob(id, "node1"); err != nil {
+		t.Errorf("Error occurred in ReleaseRepJob: %v", err)
+	}
+}
+
+func TestRequestStopUnclaimedRepJobs(t *testing.T) {
+	j := models.RepJob{
+		Repository: "library/stop-unclaimed",
+		PolicyID:   policyID,
+		Operation:  "transfer",
+	}
+	id, err := AddRepJob(j)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", j, err)
+		return
+	}
+	defer DeleteRepJob(id)
+
+	if err = RequestStopRepJobs(id); err != nil {
+		t.Errorf("Error occurred in RequestStopRepJobs: %v", err)
+		return
+	}
+
+	// the job is stopped without being claimed
+	if job, _ := GetRepJob(id); job == nil || job.Status != models.JobStopped {
+		t.Errorf("Unexpected job after requesting to stop: %+v", job)
+		return
+	}
+	claimed, err := ClaimRepJob("node1", time.Minute, nil, nil)
+	if err != nil {
+		t.Errorf("Error occurred in ClaimRepJob: %v", err)
+		return
+	}
+	if claimed != nil {
+		ReleaseRepJob(claimed.ID, "node1")
+		if claimed.ID == id {
+			t.Errorf("The stopped job %d should not be claimed", id)
+		}
+	}
+}
+
+func TestRepJobActions(t *testing.T) {
+	j := models.RepJob{
+		Repository: "library/actions",
+		PolicyID:   policyID,
+		Operation:  "transfer",
+	}
+	id, err := AddRepJob(j)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", j, err)
+		return
+	}
+	defer DeleteRepJob(id)
+
+	// a pending job which is not claimed can be canceled but not stopped
+	if ok, err := StopRepJob(id); err != nil || ok {
+		t.Errorf("Unexpected result of StopRepJob for a pending job: %v, error: %v", ok, err)
+		return
+	}
+	if ok, err := CancelRepJob(id); err != nil || !ok {
+		t.Errorf("Unexpected result of CancelRepJob for a pending job: %v, error: %v", ok, err)
+		return
+	}
+	if job, _ := GetRepJob(id); job == nil || job.Status != models.JobCanceled {
+		t.Errorf("Unexpected job after canceling: %+v", job)
+		return
+	}
+
+	if ok, err := RerunRepJob(id); err != nil || !ok {
+		t.Errorf("Unexpected result of RerunRepJob for a canceled job: %v, error: %v", ok, err)
+		return
+	}
+	if ok, err := RerunRepJob(id); err != nil || ok {
+		t.Errorf("Unexpected result of RerunRepJob for a pending job: %v, error: %v", ok, err)
+		return
+	}
+
+	// a claimed job can be stopped but not canceled
+	claimed, err := ClaimRepJob("node1", time.Minute, nil, nil)
+	if err != nil || claimed == nil || claimed.ID != id {
+		t.Errorf("Unexpected job claimed: %+v, expected: %d, error: %v", claimed, id, err)
+		return
+	}
+	defer ReleaseRepJob(id, "node1")
+	if ok, err := CancelRepJob(id); err != nil || ok {
+		t.Errorf("Unexpected result of CancelRepJob for a claimed job: %v, error: %v", ok, err)
+		return
+	}
+	for i := 0; i < 2; i++ {
+		if ok, err := StopRepJob(id); err != nil || !ok {
+			t.Errorf("Unexpected result of StopRepJob for a claimed job: %v, error: %v", ok, err)
+			return
+		}
+	}
+}
+
+func TestGetRepPoliciesAtJobLimit(t *testing.T) {
+	policy, err := GetRepPolicy(policyID)
+	if err != nil || policy == nil {
+		t.Errorf("Failed to get policy %d: %v, error: %v", policyID, policy, err)
+		return
+	}
+	policy.MaxConcurrentJobs = 1
+	if err = UpdateRepPolicy(policy); err != nil {
+		t.Errorf("Error occurred in UpdateRepPolicy: %v", err)
+		return
+	}
+	defer func() {
+		policy.MaxConcurrentJobs = 0
+		UpdateRepPolicy(policy)
+	}()
+
+	j := models.RepJob{
+		Repository: "library/limit",
+		PolicyID:   policyID,
+		Operation:  "transfer",
+	}
+	id, err := AddRepJob(j)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", j, err)
+		return
+	}
+	defer DeleteRepJob(id)
+
+	ids, err := GetRepPoliciesAtJobLimit()
+	if err != nil {
+		t.Errorf("Error occurred in GetRepPoliciesAtJobLimit: %v", err)
+		return
+	}
+	if len(ids) != 0 {
+		t.Errorf("Unexpected policies at limit before claiming: %v", ids)
+		return
+	}
+
+	claimed, err := ClaimRepJob("node1", time.Minute, nil, nil)
+	if err != nil || claimed == nil || claimed.ID != id {
+		t.Errorf("Unexpected job claimed: %+v, expected: %d, error: %v", claimed, id, err)
+		return
+	}
+	defer ReleaseRepJob(id, "node1")
+
+	ids, err = GetRepPoliciesAtJobLimit()
+	if err != nil {
+		t.Errorf("Error occurred in GetRepPoliciesAtJobLimit: %v", err)
+		return
+	}
+	if len(ids) != 1 || ids[0] != policyID {
+		t.Errorf("Unexpected policies at limit: %v, expected: %d", ids, policyID)
+	}
+}
+
+func TestRepExecutions(t *testing.T) {
+	eid, err := AddRepExecution(models.RepExecution{
+		PolicyID: policyID,
+		Trigger:  models.RepTriggerManual,
+	})
+	if err != nil {
+		t.Errorf("Error occurred in AddRepExecution: %v", err)
+		return
+	}
+
+	var ids []int64
+	for _, status := range []string{models.JobFinished, models.JobError} {
+		j := models.RepJob{
+			Repository:  "library/execution",
+			PolicyID:    policyID,
+			ExecutionID: eid,
+			Operation:   "transfer",
+		}
+		id, err := AddRepJob(j)
+		if err != nil {
+			t.Errorf("Failed to add job: %+v, error: %v", j, err)
+			return
+		}
+		ids = append(ids, id)
+		if err = UpdateRepJobStatus(id, status); err != nil {
+			t.Errorf("Failed to update status of job %d, error: %v", id, err)
+			return
+		}
+	}
+
+	execution, err := GetRepExecution(eid)
+	if err != nil {
+		t.Errorf("Error occurred in GetRepExecution: %v", err)
+		return
+	}
+	if execution == nil || execution.Trigger != models.RepTriggerManual || len(execution.Jobs) != 2 ||
+		execution.Status != models.ExecutionFailed {
+		t.Errorf("Unexpected execution: %+v", execution)
+		return
+	}
+
+	executions, err := GetRepExecutionsByPolicy(policyID, 1)
+	if err != nil {
+		t.Errorf("Error occurred in GetRepExecutionsByPolicy: %v", err)
+		return
+	}
+	if len(executions) != 1 || executions[0].ID != eid || executions[0].Total != 2 ||
+		executions[0].StatusCounts[models.JobError] != 1 {
+		t.Errorf("Unexpected executions: %+v", executions)
+		return
+	}
+
+	// the execution is kept as long as it has jobs
+	if err = DeleteRepExecutionsWithoutJobs(eid); err != nil {
+		t.Errorf("Error occurred in DeleteRepExecutionsWithoutJobs: %v", err)
+		return
+	}
+	if execution, _ = GetRepExecution(eid); execution == nil {
+		t.Errorf("Execution %d is deleted while it has jobs", eid)
+		return
+	}
+	for _, id := range ids {
+		DeleteRepJob(id)
+	}
+	if err = DeleteRepExecutionsWithoutJobs(eid); err != nil {
+		t.Errorf("Error occurred in DeleteRepExecutionsWithoutJobs: %v", err)
+		return
+	}
+	if execution, _ = GetRepExecution(eid); execution != nil {
+		t.Errorf("Execution %d is not deleted after its jobs are deleted", eid)
+	}
+}
+
 func TestDeleteRepTarget(t *testing.T) {
 	err := DeleteRepTarget(targetID)
 	if err != nil {
@@ -1295,6 +2042,116 @@ func TestUpdateRepPolicy(t *testing.T) {
 	}
 }
 
+func TestUpdateRepPolicyFilters(t *testing.T) {
+	policy := &models.RepPolicy{
+		ID:   policyID,
+		Name: "new_policy_name",
+		Filters: []*models.RepFilter{
+			&models.RepFilter{
+				Kind:    models.FilterKindTag,
+				Type:    models.FilterTypeGlob,
+				Pattern: "release-*",
+			},
+		},
+	}
+	if err := UpdateRepPolicy(policy); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+
+	p, err := GetRepPolicy(policyID)
+	if err != nil {
+		t.Fatalf("failed to get policy %d: %v", policyID, err)
+	}
+	if len(p.Filters) != 1 || p.Filters[0].Pattern != "release-*" {
+		t.Errorf("unexpected filters of policy %d: %s", policyID, p.FiltersStr)
+	}
+	if !p.MatchTag("release-1.0") || p.MatchTag("latest") {
+		t.Errorf("the filters of policy %d do not work as expected", policyID)
+	}
+
+	policy.Filters = nil
+	if err := UpdateRepPolicy(policy); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+}
+
+func TestGetScheduledRepPolicies(t *testing.T) {
+	policy := &models.RepPolicy{
+		ID:       policyID,
+		Name:     "new_policy_name",
+		TargetID: targetID,
+		Enabled:  1,
+		CronStr:  "0 2 * * *",
+	}
+	if err := UpdateRepPolicy(policy); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+
+	policies, err := GetScheduledRepPolicies()
+	if err != nil {
+		t.Fatalf("failed to get scheduled policies: %v", err)
+	}
+
+	found := false
+	for _, p := range policies {
+		if p.ID == policyID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("policy %d is not in the list of scheduled policies", policyID)
+	}
+}
+
+func TestUpdateRepPolicySchedule(t *testing.T) {
+	last := time.Now().Truncate(time.Second)
+	next := last.Add(1 * time.Hour)
+	if err := UpdateRepPolicySchedule(policyID, last, next); err != nil {
+		t.Fatalf("failed to update schedule of policy %d: %v", policyID, err)
+	}
+
+	p, err := GetRepPolicy(policyID)
+	if err != nil {
+		t.Fatalf("failed to get policy %d: %v", policyID, err)
+	}
+	if p.LastRunTime.Unix() != last.Unix() || p.NextRunTime.Unix() != next.Unix() {
+		t.Errorf("unexpected schedule: last run time %v, next run time %v, expected: %v, %v",
+			p.LastRunTime, p.NextRunTime, last, next)
+	}
+
+	// only one of the nodes can claim the run
+	later := next.Add(1 * time.Hour)
+	claimed, err := ClaimRepPolicySchedule(policyID, p.NextRunTime, next, later)
+	if err != nil || !claimed {
+		t.Fatalf("failed to claim the run of policy %d: %v, %v", policyID, claimed, err)
+	}
+	claimed, err = ClaimRepPolicySchedule(policyID, p.NextRunTime, next, later)
+	if err != nil || claimed {
+		t.Errorf("the run of policy %d should have been claimed already: %v, %v", policyID, claimed, err)
+	}
+
+	p, err = GetRepPolicy(policyID)
+	if err != nil {
+		t.Fatalf("failed to get policy %d: %v", policyID, err)
+	}
+	if p.NextRunTime.Unix() != later.Unix() {
+		t.Errorf("unexpected next run time %v, expected: %v", p.NextRunTime, later)
+	}
+
+	// updating the policy clears the next run time
+	if err := UpdateRepPolicy(p); err != nil {
+		t.Fatalf("failed to update policy: %v", err)
+	}
+	p, err = GetRepPolicy(policyID)
+	if err != nil {
+		t.Fatalf("failed to get policy %d: %v", policyID, err)
+	}
+	if !p.NextRunTime.IsZero() {
+		t.Errorf("next run time should be cleared after updating the policy, but got: %v", p.NextRunTime)
+	}
+}
+
 func TestDeleteRepPolicy(t *testing.T) {
 	err := DeleteRepPolicy(policyID)
 	if err != nil {
@@ -1335,10 +2192,35 @@ func TestResetRepJobs(t *testing.T) {
 		t.Errorf("Failed to add job: %+v, error: %v", job2, err)
 		return
 	}
-	err = ResetRunningJobs()
+	// the running job owned by another node is untouched
+	job3 := models.RepJob{
+		Repository:      "library/ubuntuc",
+		PolicyID:        policyID,
+		Operation:       "transfer",
+		Status:          models.JobRunning,
+		LeaseOwner:      "other",
+		LeaseExpireTime: time.Now().Add(time.Hour),
+	}
+	id3, err := AddRepJob(job3)
+	if err != nil {
+		t.Errorf("Failed to add job: %+v, error: %v", job3, err)
+		return
+	}
+	defer DeleteRepJob(id3)
+
+	err = ResetRunningJobs("owner")
 	if err != nil {
 		t.Errorf("Failed to reset running jobs, error: %v", err)
 	}
+	j3, err := GetRepJob(id3)
+	if err != nil {
+		t.Errorf("Failed to get rep job, id: %d, error: %v", id3, err)
+		return
+	}
+	if j3.Status != models.JobRunning || j3.LeaseOwner != "other" {
+		t.Errorf("The rep job: %d owned by other node should not be reset, status: %s, owner: %s", id3, j3.Status, j3.LeaseOwner)
+		return
+	}
 	j1, err := GetRepJob(id1)
 	if err != nil {
 		t.Errorf("Failed to get rep job, id: %d, error: %v", id1, err)
@@ -1382,6 +2264,72 @@ func TestGetJobByStatus(t *testing.T) {
 	}
 }
 
+func TestGetEndedRepJobs(t *testing.T) {
+	var ids []int64
+	for _, status := range []string{models.JobFinished, models.JobError, models.JobPending} {
+		j := models.RepJob{
+			Repository: "library/ended",
+			PolicyID:   policyID,
+			Operation:  "transfer",
+			Status:     status,
+		}
+		id, err := AddRepJob(j)
+		if err != nil {
+			t.Errorf("Failed to add job: %+v, error: %v", j, err)
+			return
+		}
+		defer DeleteRepJob(id)
+		if err = UpdateRepJobStatus(id, status); err != nil {
+			t.Errorf("Failed to update status of job %d, error: %v", id, err)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	jobs, err := GetEndedRepJobs()
+	if err != nil {
+		t.Errorf("Error occurred in GetEndedRepJobs: %v", err)
+		return
+	}
+	var ended []int64
+	for _, j := range jobs {
+		if j.Repository == "library/ended" {
+			ended = append(ended, j.ID)
+		}
+	}
+	if len(ended) != 2 || ended[0] != ids[1] || ended[1] != ids[0] {
+		t.Errorf("Unexpected ended jobs: %v, expected: [%d %d]", ended, ids[1], ids[0])
+	}
+}
+
+func TestAcquireLease(t *testing.T) {
+	name := "test_lease"
+	defer GetOrmer().Raw(`delete from job_lease where name = ?`, name).Exec()
+
+	acquired, err := AcquireLease(name, "node1", 1*time.Minute)
+	if err != nil || !acquired {
+		t.Errorf("Failed to acquire the lease, acquired: %v, error: %v", acquired, err)
+		return
+	}
+
+	// held by node1
+	if acquired, err = AcquireLease(name, "node2", 1*time.Minute); err != nil || acquired {
+		t.Errorf("Unexpected result of acquiring the lease held by another node, acquired: %v, error: %v", acquired, err)
+	}
+
+	// renewed by node1
+	if acquired, err = AcquireLease(name, "node1", 0); err != nil || !acquired {
+		t.Errorf("Failed to renew the lease, acquired: %v, error: %v", acquired, err)
+		return
+	}
+
+	// expired
+	time.Sleep(2 * time.Second)
+	if acquired, err = AcquireLease(name, "node2", 1*time.Minute); err != nil || !acquired {
+		t.Errorf("Failed to acquire the expired lease, acquired: %v, error: %v", acquired, err)
+	}
+}
+
 func TestGetOrmer(t *testing.T) {
 	o := GetOrmer()
 	if o == nil {
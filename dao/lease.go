@@ -0,0 +1,52 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package dao
+
+import (
+	"time"
+)
+
+// AcquireLease acquires the lease with the name for the owner, or renews it if the owner holds it
+// already. It returns false if the lease is held by another owner and has not expired. The leases
+// make sure the periodical tasks are performed by only one node when several nodes are deployed.
+func AcquireLease(name, owner string, lease time.Duration) (bool, error) {
+	o := GetOrmer()
+	seconds := int64(lease.Seconds())
+	r, err := o.Raw(`insert ignore into job_lease (name, owner, expire_time)
+		values (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))`, name, owner, seconds).Exec()
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 1 {
+		return true, nil
+	}
+
+	if _, err = o.Raw(`update job_lease set owner = ?, expire_time = DATE_ADD(NOW(), INTERVAL ? SECOND)
+		where name = ? and (owner = ? or expire_time < NOW())`, owner, seconds, name, owner).Exec(); err != nil {
+		return false, err
+	}
+
+	// the rows affected are not checked, as a renewal within the same second changes nothing
+	var holder string
+	if err = o.Raw(`select owner from job_lease where name = ?`, name).QueryRow(&holder); err != nil {
+		return false, err
+	}
+	return holder == owner, nil
+}
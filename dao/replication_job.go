@@ -16,6 +16,7 @@
 package dao
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -23,6 +24,7 @@ import (
 
 	"github.com/astaxie/beego/orm"
 	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/log"
 )
 
 // AddRepTarget ...
@@ -76,7 +78,8 @@ func DeleteRepTarget(id int64) error {
 // UpdateRepTarget ...
 func UpdateRepTarget(target models.RepTarget) error {
 	o := GetOrmer()
-	_, err := o.Update(&target, "URL", "Name", "Username", "Password")
+	_, err := o.Update(&target, "URL", "Name", "Username", "Password", "Type",
+		"BandwidthLimit", "TimeWindows", "MaxConcurrentJobs")
 	return err
 }
 
@@ -105,7 +108,16 @@ func FilterRepTargets(name string) ([]*models.RepTarget, error) {
 // AddRepPolicy ...
 func AddRepPolicy(policy models.RepPolicy) (int64, error) {
 	o := GetOrmer()
-	sqlTpl := `insert into replication_policy (name, project_id, target_id, enabled, description, cron_str, start_time, creation_time, update_time ) values (?, ?, ?, ?, ?, ?, %s, NOW(), NOW())`
+	if err := genFiltersStrForPolicy(&policy); err != nil {
+		return 0, err
+	}
+	if len(policy.Direction) == 0 {
+		policy.Direction = models.RepDirectionPush
+	}
+	if len(policy.ConflictMode) == 0 {
+		policy.ConflictMode = models.ConflictOverwrite
+	}
+	sqlTpl := `insert into replication_policy (name, project_id, target_id, enabled, description, cron_str, direction, filters, max_concurrent_jobs, target_project, target_repo_prefix, mirror, mirror_threshold, conflict_mode, start_time, creation_time, update_time ) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, %s, NOW(), NOW())`
 	var sql string
 	if policy.Enabled == 1 {
 		sql = fmt.Sprintf(sqlTpl, "NOW()")
@@ -116,7 +128,8 @@ func AddRepPolicy(policy models.RepPolicy) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	r, err := p.Exec(policy.Name, policy.ProjectID, policy.TargetID, policy.Enabled, policy.Description, policy.CronStr)
+	r, err := p.Exec(policy.Name, policy.ProjectID, policy.TargetID, policy.Enabled, policy.Description, policy.CronStr, policy.Direction, policy.FiltersStr, policy.MaxConcurrentJobs,
+		policy.TargetProject, policy.TargetRepoPrefix, policy.Mirror, policy.MirrorThreshold, policy.ConflictMode)
 	if err != nil {
 		return 0, err
 	}
@@ -138,6 +151,10 @@ func GetRepPolicy(id int64) (*models.RepPolicy, error) {
 		return nil, err
 	}
 
+	if err := genFiltersForPolicy(&policy); err != nil {
+		return nil, err
+	}
+
 	return &policy, nil
 }
 
@@ -149,7 +166,9 @@ func FilterRepPolicies(name string, projectID int64) ([]*models.RepPolicy, error
 
 	sql := `select rp.id, rp.project_id, p.name as project_name, rp.target_id, 
 				rt.name as target_name, rp.name, rp.enabled, rp.description,
-				rp.cron_str, rp.start_time, rp.creation_time, rp.update_time, 
+				rp.cron_str, rp.direction, rp.filters, rp.start_time, rp.last_run_time, rp.next_run_time,
+				rp.max_concurrent_jobs, rp.target_project, rp.target_repo_prefix, rp.mirror, rp.mirror_threshold,
+				rp.conflict_mode, rp.creation_time, rp.update_time, 
 				count(rj.status) as error_job_count 
 			from replication_policy rp 
 			left join project p on rp.project_id=p.project_id 
@@ -175,6 +194,9 @@ func FilterRepPolicies(name string, projectID int64) ([]*models.RepPolicy, error
 	if _, err := o.Raw(sql, args).QueryRows(&policies); err != nil {
 		return nil, err
 	}
+	if err := genFiltersForPolicy(policies...); err != nil {
+		return nil, err
+	}
 	return policies, nil
 }
 
@@ -192,6 +214,10 @@ func GetRepPolicyByName(name string) (*models.RepPolicy, error) {
 		return nil, err
 	}
 
+	if err := genFiltersForPolicy(&policy); err != nil {
+		return nil, err
+	}
+
 	return &policy, nil
 }
 
@@ -206,6 +232,10 @@ func GetRepPolicyByProject(projectID int64) ([]*models.RepPolicy, error) {
 		return nil, err
 	}
 
+	if err := genFiltersForPolicy(policies...); err != nil {
+		return nil, err
+	}
+
 	return policies, nil
 }
 
@@ -220,6 +250,10 @@ func GetRepPolicyByTarget(targetID int64) ([]*models.RepPolicy, error) {
 		return nil, err
 	}
 
+	if err := genFiltersForPolicy(policies...); err != nil {
+		return nil, err
+	}
+
 	return policies, nil
 }
 
@@ -234,13 +268,30 @@ func GetRepPolicyByProjectAndTarget(projectID, targetID int64) ([]*models.RepPol
 		return nil, err
 	}
 
+	if err := genFiltersForPolicy(policies...); err != nil {
+		return nil, err
+	}
+
 	return policies, nil
 }
 
 // UpdateRepPolicy ...
 func UpdateRepPolicy(policy *models.RepPolicy) error {
 	o := GetOrmer()
-	_, err := o.Update(policy, "TargetID", "Name", "Enabled", "Description", "CronStr")
+	// clear the next run time, so that it will be recalculated by job service
+	// according to the new cron string
+	policy.NextRunTime = time.Time{}
+	if err := genFiltersStrForPolicy(policy); err != nil {
+		return err
+	}
+	if len(policy.Direction) == 0 {
+		policy.Direction = models.RepDirectionPush
+	}
+	if len(policy.ConflictMode) == 0 {
+		policy.ConflictMode = models.ConflictOverwrite
+	}
+	_, err := o.Update(policy, "TargetID", "Name", "Enabled", "Description", "CronStr", "Direction", "FiltersStr", "MaxConcurrentJobs",
+		"TargetProject", "TargetRepoPrefix", "Mirror", "MirrorThreshold", "ConflictMode", "NextRunTime")
 	return err
 }
 
@@ -262,7 +313,7 @@ func UpdateRepPolicyEnablement(id int64, enabled int) error {
 	var err error
 	if enabled == 1 {
 		p.StartTime = time.Now()
-		_, err = o.Update(&p, "Enabled", "StartTime")
+		_, err = o.Update(&p, "Enabled", "StartTime", "NextRunTime")
 	} else {
 		_, err = o.Update(&p, "Enabled")
 	}
@@ -270,6 +321,83 @@ func UpdateRepPolicyEnablement(id int64, enabled int) error {
 	return err
 }
 
+// GetScheduledRepPolicies returns the enabled policies which have a cron string
+func GetScheduledRepPolicies() ([]*models.RepPolicy, error) {
+	o := GetOrmer()
+	sql := `select * from replication_policy where enabled = 1 and cron_str is not null and cron_str <> ''`
+
+	var policies []*models.RepPolicy
+
+	if _, err := o.Raw(sql).QueryRows(&policies); err != nil {
+		return nil, err
+	}
+
+	if err := genFiltersForPolicy(policies...); err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// UpdateRepPolicySchedule updates the last and next run time of the policy
+func UpdateRepPolicySchedule(id int64, lastRunTime, nextRunTime time.Time) error {
+	o := GetOrmer()
+	p := models.RepPolicy{
+		ID:          id,
+		LastRunTime: lastRunTime,
+		NextRunTime: nextRunTime,
+	}
+	_, err := o.Update(&p, "LastRunTime", "NextRunTime")
+	return err
+}
+
+// ClaimRepPolicySchedule moves the schedule of the policy forward if its next run time is still the
+// expected one. It returns true if the schedule is updated, which means the caller has claimed the run
+// and no other node will trigger it.
+func ClaimRepPolicySchedule(id int64, expected, lastRunTime, nextRunTime time.Time) (bool, error) {
+	r, err := GetOrmer().Raw(`update replication_policy set last_run_time = ?, next_run_time = ?
+		where id = ? and next_run_time = ?`, lastRunTime, nextRunTime, id, expected).Exec()
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	return n == 1, err
+}
+
+func genFiltersStrForPolicy(policy *models.RepPolicy) error {
+	policy.FiltersStr = ""
+	if len(policy.Filters) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(policy.Filters)
+	if err != nil {
+		return err
+	}
+	policy.FiltersStr = string(data)
+	return nil
+}
+
+func genFiltersForPolicy(policies ...*models.RepPolicy) error {
+	for _, p := range policies {
+		if len(p.FiltersStr) == 0 {
+			continue
+		}
+		if err := json.Unmarshal([]byte(p.FiltersStr), &p.Filters); err != nil {
+			return fmt.Errorf("failed to parse the filters of policy %d: %v", p.ID, err)
+		}
+		// the patterns are compiled once when the policy is loaded
+		for _, f := range p.Filters {
+			if f == nil {
+				return fmt.Errorf("null filter of policy %d", p.ID)
+			}
+			if err := f.Validate(); err != nil {
+				return fmt.Errorf("invalid filter of policy %d: %v", p.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
 // EnableRepPolicy ...
 func EnableRepPolicy(id int64) error {
 	return UpdateRepPolicyEnablement(id, 1)
@@ -300,6 +428,9 @@ func GetRepJob(id int64) (*models.RepJob, error) {
 	if err == orm.ErrNoRows {
 		return nil, nil
 	}
+	if err != nil {
+		return nil, err
+	}
 	genTagListForJob(&j)
 	return &j, nil
 }
@@ -355,6 +486,27 @@ func FilterRepJobs(policyID int64, repository, status string, startTime,
 	return jobs, nil
 }
 
+// FilterJobs filters jobs of all kinds by operation and status, from the latest to the earliest
+func FilterJobs(operation, status string, limit int) ([]*models.RepJob, error) {
+	qs := repJobQs()
+	if len(operation) != 0 {
+		qs = qs.Filter("operation", operation)
+	}
+	if len(status) != 0 {
+		qs = qs.Filter("status", status)
+	}
+	if limit != 0 {
+		qs = qs.Limit(limit)
+	}
+
+	var jobs []*models.RepJob
+	if _, err := qs.OrderBy("-id").All(&jobs); err != nil {
+		return nil, err
+	}
+	genTagListForJob(jobs...)
+	return jobs, nil
+}
+
 // GetRepJobToStop get jobs that are possibly being handled by workers of a certain policy.
 func GetRepJobToStop(policyID int64) ([]*models.RepJob, error) {
 	var res []*models.RepJob
@@ -389,18 +541,285 @@ func UpdateRepJobStatus(id int64, status string) error {
 	num, err := o.Update(&j, "Status")
 	if num == 0 {
 		err = fmt.Errorf("Failed to update replication job with id: %d %s", id, err.Error())
+		return err
+	}
+	// the interrupted upload sessions can not be resumed once the job ends
+	if status == models.JobFinished || status == models.JobError ||
+		status == models.JobStopped || status == models.JobCanceled {
+		err = DeleteRepJobUploads(id)
+	}
+	return err
+}
+
+// UpdateRepJobRetry updates the status of the job to retrying, records the retry count and
+// sets the next retry time to delay from now.
+func UpdateRepJobRetry(id int64, retryCount int, delay time.Duration) error {
+	o := GetOrmer()
+	_, err := o.Raw(`update replication_job set status = ?, retry_count = ?,
+		next_retry_time = DATE_ADD(NOW(), INTERVAL ? SECOND) where id = ?`,
+		models.JobRetrying, retryCount, int64(delay.Seconds()), id).Exec()
+	return err
+}
+
+// UpdateRepJobProgress stores the progress of the job, the update time of the job is kept unchanged.
+func UpdateRepJobProgress(id int64, progress *models.RepJobProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
 	}
+	o := GetOrmer()
+	_, err = o.Raw(`update replication_job set progress = ?, update_time = update_time where id = ?`,
+		string(data), id).Exec()
+	return err
+}
+
+// UpdateRepJobCheckpoint records the tags which have not been replicated by the job, the update time is kept unchanged.
+// An empty list is recorded as "[]" rather than NULL, which means the job has no checkpoint.
+func UpdateRepJobCheckpoint(id int64, tags []string) error {
+	if tags == nil {
+		tags = []string{}
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	o := GetOrmer()
+	_, err = o.Raw(`update replication_job set checkpoint = ?, update_time = update_time where id = ?`,
+		string(data), id).Exec()
+	return err
+}
+
+// ResetRunningJobs puts the running jobs owned by the node back to the queue, it is called when
+// the node starts. The running jobs owned by other nodes are untouched, they will be claimed again
+// once their leases expire.
+func ResetRunningJobs(owner string) error {
+	o := GetOrmer()
+	_, err := o.Raw(`update replication_job set status = ?, lease_owner = NULL, lease_expire_time = NULL
+		where status = ? and (lease_owner is NULL or lease_owner = ?)`,
+		models.JobPending, models.JobRunning, owner).Exec()
+	if err != nil {
+		return err
+	}
+	_, err = o.Raw(`update replication_job set lease_owner = NULL, lease_expire_time = NULL,
+		update_time = update_time where lease_owner = ?`, owner).Exec()
+	return err
+}
+
+// ClaimRepJob picks the next job from the queue and leases it to the owner for a period of time,
+// during which the job will not be claimed by others. Jobs with higher priority are claimed first,
+// and jobs with the same priority are claimed in the order they were created. A retrying job
+// will not be claimed until its next retry time, and a running job is claimed only if its lease
+// has expired, i.e. the node running it is gone. The jobs of the policies in excludedPolicies
+// and of the policies whose targets are in excludedTargets are skipped and stay in the queue.
+// The jobs requested to stop whose nodes are gone are moved to stopped rather than claimed.
+// It returns nil if there is no job to claim.
+func ClaimRepJob(owner string, lease time.Duration, excludedPolicies, excludedTargets []int64) (*models.RepJob, error) {
+	// the shared ormer can not be used in transaction
+	o := orm.NewOrm()
+	if err := o.Begin(); err != nil {
+		return nil, err
+	}
+
+	abandoned := `stop_requested = 1 and status in (?, ?, ?)
+		and (lease_owner is NULL or lease_expire_time is NULL or lease_expire_time < NOW())`
+	// the interrupted upload sessions of the jobs stopped here can not be resumed
+	if _, err := o.Raw(`delete from replication_job_upload where job_id in
+		(select id from replication_job where `+abandoned+`)`,
+		models.JobPending, models.JobRunning, models.JobRetrying).Exec(); err != nil {
+		o.Rollback()
+		return nil, err
+	}
+	if _, err := o.Raw(`update replication_job set status = ?, next_retry_time = NULL where `+abandoned,
+		models.JobStopped, models.JobPending, models.JobRunning, models.JobRetrying).Exec(); err != nil {
+		o.Rollback()
+		return nil, err
+	}
+
+	sql := `select * from replication_job
+		where (status in (?, ?) or (status = ? and (next_retry_time is NULL or next_retry_time <= NOW())))
+		and (lease_owner is NULL or lease_expire_time is NULL or lease_expire_time < NOW()) `
+	args := []interface{}{models.JobPending, models.JobRunning, models.JobRetrying}
+	if len(excludedPolicies) > 0 {
+		sql += `and policy_id not in (` + strings.TrimRight(strings.Repeat("?,", len(excludedPolicies)), ",") + `) `
+		for _, id := range excludedPolicies {
+			args = append(args, id)
+		}
+	}
+	if len(excludedTargets) > 0 {
+		sql += `and policy_id not in (select id from replication_policy where target_id in (` +
+			strings.TrimRight(strings.Repeat("?,", len(excludedTargets)), ",") + `)) `
+		for _, id := range excludedTargets {
+			args = append(args, id)
+		}
+	}
+	sql += `order by priority desc, id asc limit 1 for update`
+	j := models.RepJob{}
+	err := o.Raw(sql, args...).QueryRow(&j)
+	if err == orm.ErrNoRows {
+		o.Rollback()
+		return nil, nil
+	}
+	if err != nil {
+		o.Rollback()
+		return nil, err
+	}
+
+	if _, err := o.Raw(`update replication_job set lease_owner = ?,
+		lease_expire_time = DATE_ADD(NOW(), INTERVAL ? SECOND) where id = ?`,
+		owner, int64(lease.Seconds()), j.ID).Exec(); err != nil {
+		o.Rollback()
+		return nil, err
+	}
+
+	if err := o.Commit(); err != nil {
+		return nil, err
+	}
+
+	j.LeaseOwner = owner
+	genTagListForJob(&j)
+	return &j, nil
+}
+
+// GetRepPoliciesAtJobLimit returns the IDs of the policies whose running jobs, i.e. the jobs holding
+// a lease, have reached their max_concurrent_jobs
+func GetRepPoliciesAtJobLimit() ([]int64, error) {
+	var ids []int64
+	_, err := GetOrmer().Raw(`select p.id from replication_policy p where p.max_concurrent_jobs > 0
+		and p.max_concurrent_jobs <= (select count(*) from replication_job j where j.policy_id = p.id
+		and j.lease_owner is not NULL and j.lease_expire_time >= NOW())`).QueryRows(&ids)
+	return ids, err
+}
+
+// GetRepTargetsAtJobLimit returns the IDs of the targets whose running jobs, i.e. the jobs holding
+// a lease, have reached their max_concurrent_jobs
+func GetRepTargetsAtJobLimit() ([]int64, error) {
+	var ids []int64
+	_, err := GetOrmer().Raw(`select t.id from replication_target t where t.max_concurrent_jobs > 0
+		and t.max_concurrent_jobs <= (select count(*) from replication_job j
+		join replication_policy p on j.policy_id = p.id where p.target_id = t.id
+		and j.lease_owner is not NULL and j.lease_expire_time >= NOW())`).QueryRows(&ids)
+	return ids, err
+}
+
+// ReleaseRepJob releases the lease of the job held by the owner, the update time is kept unchanged.
+func ReleaseRepJob(id int64, owner string) error {
+	o := GetOrmer()
+	_, err := o.Raw(`update replication_job set lease_owner = NULL, lease_expire_time = NULL,
+		update_time = update_time where id = ? and lease_owner = ?`, id, owner).Exec()
 	return err
 }
 
-// ResetRunningJobs update all running jobs status to pending
-func ResetRunningJobs() error {
+// RenewRepJobLeases extends the leases of all the jobs owned by the owner, the update time is kept unchanged.
+func RenewRepJobLeases(owner string, lease time.Duration) error {
 	o := GetOrmer()
-	sql := fmt.Sprintf("update replication_job set status = '%s' where status = '%s'", models.JobPending, models.JobRunning)
-	_, err := o.Raw(sql).Exec()
+	_, err := o.Raw(`update replication_job set lease_expire_time = DATE_ADD(NOW(), INTERVAL ? SECOND),
+		update_time = update_time where lease_owner = ?`, int64(lease.Seconds()), owner).Exec()
 	return err
 }
 
+// RequestStopRepJobs stops the jobs: the ones waiting in the queue and not claimed by any node are
+// moved to stopped directly, the others are marked as requested to stop and the nodes owning them
+// will stop them.
+func RequestStopRepJobs(ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []interface{}{}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	in := `id in (` + strings.TrimRight(strings.Repeat("?,", len(ids)), ",") + `)`
+	o := GetOrmer()
+
+	// the jobs which are not claimed by any node are stopped directly, their interrupted
+	// upload sessions can not be resumed
+	unclaimed := in + ` and status in (?, ?)
+		and (lease_owner is NULL or lease_expire_time is NULL or lease_expire_time < NOW())`
+	if _, err := o.Raw(`delete from replication_job_upload where job_id in
+		(select id from replication_job where `+unclaimed+`)`,
+		append(args, models.JobPending, models.JobRetrying)...).Exec(); err != nil {
+		return err
+	}
+	if _, err := o.Raw(`update replication_job set status = ?, next_retry_time = NULL where `+unclaimed,
+		append(append([]interface{}{models.JobStopped}, args...), models.JobPending, models.JobRetrying)...).Exec(); err != nil {
+		return err
+	}
+
+	_, err := o.Raw(`update replication_job set stop_requested = 1, update_time = update_time where `+in+
+		` and status in (?, ?, ?)`, append(args, models.JobPending, models.JobRunning, models.JobRetrying)...).Exec()
+	return err
+}
+
+// StopRepJob requests the job to stop if it is running or has been claimed by a node, the node owning
+// the job will stop it. It returns false if the job is not in such a state.
+func StopRepJob(id int64) (bool, error) {
+	// the job may have been requested to stop already, in which case no row is changed by the update,
+	// so the state is checked separately
+	cond := `id = ? and (status = ? or (status = ? and lease_owner is not NULL and lease_expire_time >= NOW()))`
+	args := []interface{}{id, models.JobRunning, models.JobPending}
+	var count int
+	if err := GetOrmer().Raw(`select count(*) from replication_job where `+cond, args...).QueryRow(&count); err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return false, nil
+	}
+	_, err := GetOrmer().Raw(`update replication_job set stop_requested = 1, update_time = update_time where `+cond,
+		args...).Exec()
+	return err == nil, err
+}
+
+// CancelRepJob cancels the job if it is waiting in the queue, i.e. it is pending or retrying and has not
+// been claimed by any node. It returns false if the job is not in such a state.
+func CancelRepJob(id int64) (bool, error) {
+	r, err := GetOrmer().Raw(`update replication_job set status = ?, next_retry_time = NULL
+		where id = ? and status in (?, ?) and (lease_owner is NULL or lease_expire_time is NULL or lease_expire_time < NOW())`,
+		models.JobCanceled, id, models.JobPending, models.JobRetrying).Exec()
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	return true, DeleteRepJobUploads(id)
+}
+
+// RerunRepJob puts the job which has failed, been stopped or been canceled back to the queue, the retry
+// count, progress, checkpoint and upload sessions are cleared so it runs from the beginning. It returns false if the job
+// is not in such a state.
+func RerunRepJob(id int64) (bool, error) {
+	r, err := GetOrmer().Raw(`update replication_job set status = ?, retry_count = 0, next_retry_time = NULL,
+		progress = NULL, checkpoint = NULL, stop_requested = 0, lease_owner = NULL, lease_expire_time = NULL
+		where id = ? and status in (?, ?, ?)`,
+		models.JobPending, id, models.JobError, models.JobStopped, models.JobCanceled).Exec()
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	return true, DeleteRepJobUploads(id)
+}
+
+// GetRepJobIDsToStop returns the IDs of the jobs owned by the owner which are requested to stop
+func GetRepJobIDsToStop(owner string) ([]int64, error) {
+	var ids []int64
+	_, err := GetOrmer().Raw(`select id from replication_job where lease_owner = ? and stop_requested = 1
+		and status in (?, ?)`, owner, models.JobPending, models.JobRunning).QueryRows(&ids)
+	return ids, err
+}
+
+// GetEndedRepJobs returns the jobs which have ended, ordered by policy and from the latest to the earliest
+func GetEndedRepJobs() ([]*models.RepJob, error) {
+	var jobs []*models.RepJob
+	_, err := repJobQs().Filter("status__in", models.JobFinished, models.JobError,
+		models.JobStopped, models.JobCanceled).OrderBy("policy_id", "-id").All(&jobs)
+	return jobs, err
+}
+
 // GetRepJobByStatus get jobs of certain statuses
 func GetRepJobByStatus(status ...string) ([]*models.RepJob, error) {
 	var res []*models.RepJob
@@ -418,5 +837,229 @@ func genTagListForJob(jobs ...*models.RepJob) {
 		if len(j.Tags) > 0 {
 			j.TagList = strings.Split(j.Tags, ",")
 		}
+		if len(j.ProgressStr) > 0 {
+			p := &models.RepJobProgress{}
+			if err := json.Unmarshal([]byte(j.ProgressStr), p); err != nil {
+				log.Warningf("failed to parse the progress of job %d: %v", j.ID, err)
+				continue
+			}
+			j.Progress = p
+		}
+	}
+}
+
+// GetLastRepJobByOperation returns the latest job of the policy whose operation is one of the operations
+func GetLastRepJobByOperation(policyID int64, operations ...string) (*models.RepJob, error) {
+	var ops []interface{}
+	for _, op := range operations {
+		ops = append(ops, interface{}(op))
+	}
+
+	var jobs []*models.RepJob
+	_, err := repJobPolicyIDQs(policyID).Filter("operation__in", ops...).
+		OrderBy("-id").Limit(1).All(&jobs)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	genTagListForJob(jobs...)
+	return jobs[0], nil
+}
+
+// AddRepDrifts inserts the differences found by a verification job
+func AddRepDrifts(drifts []*models.RepDrift) error {
+	if len(drifts) == 0 {
+		return nil
+	}
+	_, err := GetOrmer().InsertMulti(100, drifts)
+	return err
+}
+
+// DeleteRepDriftsByJob deletes the differences found by the verification job
+func DeleteRepDriftsByJob(jobID int64) error {
+	_, err := GetOrmer().QueryTable(new(models.RepDrift)).Filter("JobID", jobID).Delete()
+	return err
+}
+
+// GetRepDriftsByJob returns the differences found by the verification job
+func GetRepDriftsByJob(jobID int64) ([]*models.RepDrift, error) {
+	drifts := []*models.RepDrift{}
+	_, err := GetOrmer().QueryTable(new(models.RepDrift)).Filter("JobID", jobID).
+		OrderBy("Repository", "Tag").All(&drifts)
+	return drifts, err
+}
+
+// AddRepJobResult records the result of a tag of the job, the previous one of the same tag
+// is replaced if the job is retried or rerun.
+func AddRepJobResult(result *models.RepJobResult) error {
+	_, err := GetOrmer().Raw(`insert into replication_job_result (job_id, repository, tag, result, src_digest, dst_digest)
+		values (?, ?, ?, ?, ?, ?) on duplicate key update result = values(result), src_digest = values(src_digest),
+		dst_digest = values(dst_digest), creation_time = NOW()`, result.JobID, result.Repository, result.Tag,
+		result.Result, result.SrcDigest, result.DstDigest).Exec()
+	return err
+}
+
+// GetRepJobResults returns the results recorded by the job
+func GetRepJobResults(jobID int64) ([]*models.RepJobResult, error) {
+	results := []*models.RepJobResult{}
+	_, err := GetOrmer().QueryTable(new(models.RepJobResult)).Filter("JobID", jobID).
+		OrderBy("Repository", "Tag").All(&results)
+	return results, err
+}
+
+// DeleteRepJobResultsByJob deletes the results recorded by the job
+func DeleteRepJobResultsByJob(jobID int64) error {
+	_, err := GetOrmer().QueryTable(new(models.RepJobResult)).Filter("JobID", jobID).Delete()
+	return err
+}
+
+// AddRepExecution ...
+func AddRepExecution(execution models.RepExecution) (int64, error) {
+	return GetOrmer().Insert(&execution)
+}
+
+// GetRepExecution returns the execution with its jobs, the status and counts are aggregated from the jobs.
+func GetRepExecution(id int64) (*models.RepExecution, error) {
+	execution := models.RepExecution{ID: id}
+	err := GetOrmer().Read(&execution)
+	if err == orm.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*models.RepJob
+	if _, err = repJobQs().Filter("execution_id", id).OrderBy("id").All(&jobs); err != nil {
+		return nil, err
+	}
+	genTagListForJob(jobs...)
+	execution.Aggregate(jobs)
+	execution.Jobs = jobs
+	return &execution, nil
+}
+
+// GetRepExecutionsByPolicy returns the executions of the policy from the latest to the earliest, the
+// status and counts are aggregated from the jobs.
+func GetRepExecutionsByPolicy(policyID int64, limit int) ([]*models.RepExecution, error) {
+	qs := GetOrmer().QueryTable(new(models.RepExecution)).Filter("policy_id", policyID).OrderBy("-id")
+	if limit != 0 {
+		qs = qs.Limit(limit)
+	}
+	var executions []*models.RepExecution
+	if _, err := qs.All(&executions); err != nil {
+		return nil, err
 	}
+	if len(executions) == 0 {
+		return executions, nil
+	}
+
+	var ids []interface{}
+	for _, e := range executions {
+		ids = append(ids, e.ID)
+	}
+	var jobs []*models.RepJob
+	if _, err := repJobQs().Filter("execution_id__in", ids...).All(&jobs,
+		"ExecutionID", "Status", "UpdateTime"); err != nil {
+		return nil, err
+	}
+	jobsOfExecution := make(map[int64][]*models.RepJob)
+	for _, j := range jobs {
+		jobsOfExecution[j.ExecutionID] = append(jobsOfExecution[j.ExecutionID], j)
+	}
+	for _, e := range executions {
+		e.Aggregate(jobsOfExecution[e.ID])
+	}
+	return executions, nil
+}
+
+// DeleteRepExecutionsWithoutJobs deletes the executions in ids which have no job left
+func DeleteRepExecutionsWithoutJobs(ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []interface{}{}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	sql := `delete from replication_execution where id in (` +
+		strings.TrimRight(strings.Repeat("?,", len(ids)), ",") +
+		`) and id not in (select distinct execution_id from replication_job)`
+	_, err := GetOrmer().Raw(sql, args...).Exec()
+	return err
+}
+
+// GetRepJobUpload returns the interrupted upload session of the blob of the job, nil if not found.
+func GetRepJobUpload(jobID int64, digest string) (*models.RepBlobUpload, error) {
+	upload := &models.RepBlobUpload{}
+	err := GetOrmer().QueryTable(upload).Filter("JobID", jobID).Filter("Digest", digest).One(upload)
+	if err == orm.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// SaveRepJobUpload stores the interrupted upload session of the blob of the job, the previous one
+// of the same blob is replaced.
+func SaveRepJobUpload(upload *models.RepBlobUpload) error {
+	_, err := GetOrmer().Raw(`insert into replication_job_upload (job_id, digest, location, uuid, upload_offset)
+		values (?, ?, ?, ?, ?) on duplicate key update location = values(location), uuid = values(uuid),
+		upload_offset = values(upload_offset), creation_time = NOW()`, upload.JobID, upload.Digest,
+		upload.Location, upload.UUID, upload.Offset).Exec()
+	return err
+}
+
+// DeleteRepJobUpload deletes the interrupted upload session of the blob of the job
+func DeleteRepJobUpload(jobID int64, digest string) error {
+	_, err := GetOrmer().QueryTable(new(models.RepBlobUpload)).Filter("JobID", jobID).
+		Filter("Digest", digest).Delete()
+	return err
+}
+
+// DeleteRepJobUploads deletes all the interrupted upload sessions of the job
+func DeleteRepJobUploads(jobID int64) error {
+	_, err := GetOrmer().QueryTable(new(models.RepBlobUpload)).Filter("JobID", jobID).Delete()
+	return err
+}
+
+// AddRepPlan records the plan made by the plan job, the previous one recorded by the job is replaced
+func AddRepPlan(jobID int64, plan *models.RepPlan) error {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	_, err = GetOrmer().Raw(`insert into replication_plan (job_id, policy_id, plan) values (?, ?, ?)
+		on duplicate key update plan = values(plan), creation_time = NOW()`,
+		jobID, plan.PolicyID, string(data)).Exec()
+	return err
+}
+
+// GetRepPlanByJob returns the plan made by the plan job, nil if the job has not made one
+func GetRepPlanByJob(jobID int64) (*models.RepPlan, error) {
+	var data string
+	err := GetOrmer().Raw(`select plan from replication_plan where job_id = ?`, jobID).QueryRow(&data)
+	if err == orm.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plan := &models.RepPlan{}
+	if err = json.Unmarshal([]byte(data), plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// DeleteRepPlanByJob deletes the plan made by the plan job
+func DeleteRepPlanByJob(jobID int64) error {
+	_, err := GetOrmer().Raw(`delete from replication_plan where job_id = ?`, jobID).Exec()
+	return err
 }
@@ -0,0 +1,247 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/job/config"
+	"github.com/vmware/harbor/job/utils"
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/log"
+)
+
+// the name of the lease which the node removing the jobs out of retention holds
+const cleanupLease = "job_cleanup"
+
+// ScheduleCleanup is a loop which removes the ended jobs out of the retention configured by
+// JOB_RETENTION_DAYS and JOB_RETENTION_COUNT periodically. It returns immediately if neither is set.
+// It runs on every node but only the node holding the lease in DB removes the jobs, the log files
+// are removed from LOG_DIR of that node, which must be shared by all the nodes.
+func ScheduleCleanup() {
+	days, count := config.JobRetentionDays(), config.JobRetentionCount()
+	if days == 0 && count == 0 {
+		log.Info("No retention of jobs is configured, the jobs will not be cleaned up")
+		return
+	}
+	interval := config.JobCleanupInterval()
+	for {
+		// the lease outlasts the interval, so that the node holding it renews it before it expires
+		acquired, err := dao.AcquireLease(cleanupLease, config.NodeID(), 2*interval)
+		if err != nil {
+			log.Errorf("Failed to acquire the lease of cleanup, error: %v", err)
+		} else if acquired {
+			if _, err := CleanupJobs(days, count); err != nil {
+				log.Errorf("Failed to clean up jobs, error: %v", err)
+			}
+		} else {
+			log.Debug("The lease of cleanup is held by another node, skip")
+		}
+		time.Sleep(interval)
+	}
+}
+
+// CleanupJobs removes the ended jobs which were updated more than days ago or are not among
+// the latest count jobs of their policies, 0 means no limit. The latest failed job of each policy
+// is always kept for troubleshooting. The log files are removed together with the jobs.
+func CleanupJobs(days, count int) (*models.RepJobCleanup, error) {
+	result := &models.RepJobCleanup{
+		JobIDs: []int64{},
+	}
+	if days == 0 && count == 0 {
+		return result, nil
+	}
+
+	jobs, err := dao.GetEndedRepJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	executions := make(map[int64]struct{})
+	for _, j := range outOfRetention(jobs, days, count, time.Now()) {
+		removed, err := removeJob(j.ID)
+		if err != nil {
+			log.Errorf("Failed to remove job %d, error: %v", j.ID, err)
+			continue
+		}
+		result.JobIDs = append(result.JobIDs, j.ID)
+		if j.ExecutionID != 0 {
+			executions[j.ExecutionID] = struct{}{}
+		}
+		if removed {
+			result.LogsRemoved++
+		}
+	}
+
+	// the executions are removed together with their last jobs
+	var executionIDs []int64
+	for id := range executions {
+		executionIDs = append(executionIDs, id)
+	}
+	if err := dao.DeleteRepExecutionsWithoutJobs(executionIDs...); err != nil {
+		log.Errorf("Failed to remove executions without jobs, error: %v", err)
+	}
+
+	if len(result.JobIDs) > 0 {
+		log.Infof("%d jobs and %d log files out of retention are removed", len(result.JobIDs), result.LogsRemoved)
+	}
+	return result, nil
+}
+
+// outOfRetention returns the jobs which were updated more than days before now or are not among the
+// latest count jobs of their policies, except the latest failed job of each policy. The jobs must be
+// ordered by policy and from the latest to the earliest.
+func outOfRetention(jobs []*models.RepJob, days, count int, now time.Time) []*models.RepJob {
+	var result []*models.RepJob
+	deadline := now.AddDate(0, 0, -days)
+	var policyID int64
+	var index int
+	var failedKept bool
+	for _, j := range jobs {
+		if j.PolicyID != policyID || index == 0 {
+			policyID = j.PolicyID
+			index = 0
+			failedKept = false
+		}
+		index++
+
+		if j.Status == models.JobError && !failedKept {
+			failedKept = true
+			continue
+		}
+		if !(days > 0 && j.UpdateTime.Before(deadline)) &&
+			!(count > 0 && index > count) {
+			continue
+		}
+		result = append(result, j)
+	}
+	return result
+}
+
+// removeJob removes the log file, drifts and record of the job, it returns whether the log file existed
+func removeJob(jobID int64) (bool, error) {
+	logRemoved := true
+	if err := os.Remove(utils.GetJobLogPath(jobID)); err != nil {
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+		logRemoved = false
+	}
+	if err := dao.DeleteRepDriftsByJob(jobID); err != nil {
+		return logRemoved, err
+	}
+	if err := dao.DeleteRepJobResultsByJob(jobID); err != nil {
+		return logRemoved, err
+	}
+	if err := dao.DeleteRepPlanByJob(jobID); err != nil {
+		return logRemoved, err
+	}
+	return logRemoved, dao.DeleteRepJob(jobID)
+}
+
+// StateCleanup is the state in which the cleanup jobs remove the jobs out of retention
+const StateCleanup = "cleanup"
+
+// Retention returns the retention in days and in count of jobs per policy, the configured retention
+// is used for the one which is nil. 0 means no limit, so a limit can be disabled explicitly.
+func Retention(days, count *int) (int, int, error) {
+	d, c := config.JobRetentionDays(), config.JobRetentionCount()
+	if days != nil {
+		d = *days
+	}
+	if count != nil {
+		c = *count
+	}
+	if d < 0 || c < 0 {
+		return 0, 0, fmt.Errorf("invalid retention: %d days, %d jobs", d, c)
+	}
+	return d, c, nil
+}
+
+// cleanupParms holds the parameters of cleanup jobs, the configured retention is used
+// for the fields which are not set
+type cleanupParms struct {
+	RetentionDays  *int `json:"retention_days"`
+	RetentionCount *int `json:"retention_count"`
+}
+
+func parseCleanupParms(s string) (*cleanupParms, error) {
+	parms := &cleanupParms{}
+	if len(s) > 0 {
+		if err := json.Unmarshal([]byte(s), parms); err != nil {
+			return nil, fmt.Errorf("invalid parameters of cleanup job: %v", err)
+		}
+	}
+	days, count, err := Retention(parms.RetentionDays, parms.RetentionCount)
+	if err != nil {
+		return nil, err
+	}
+	parms.RetentionDays, parms.RetentionCount = &days, &count
+	return parms, nil
+}
+
+func validateCleanupJob(job *models.RepJob) error {
+	_, err := parseCleanupParms(job.Parameters)
+	return err
+}
+
+func loadCleanupJobParms(sm *SM, job *models.RepJob) (bool, error) {
+	parms, err := parseCleanupParms(job.Parameters)
+	if err != nil {
+		return false, err
+	}
+	sm.KindParms = parms
+	return true, nil
+}
+
+func addCleanupTransition(sm *SM) {
+	parms := sm.KindParms.(*cleanupParms)
+	cleaner := &Cleaner{
+		days:   *parms.RetentionDays,
+		count:  *parms.RetentionCount,
+		logger: sm.Logger,
+	}
+	sm.AddTransition(models.JobRunning, StateCleanup, cleaner)
+	sm.AddTransition(StateCleanup, models.JobFinished, &StatusUpdater{sm.JobID, models.JobFinished})
+}
+
+// Cleaner is the state handler of cleanup jobs
+type Cleaner struct {
+	days   int
+	count  int
+	logger *log.Logger
+}
+
+// Enter removes the jobs out of retention
+func (c *Cleaner) Enter() (string, error) {
+	c.logger.Infof("removing the ended jobs, retention: %d days, %d jobs per policy", c.days, c.count)
+	result, err := CleanupJobs(c.days, c.count)
+	if err != nil {
+		c.logger.Errorf("failed to clean up jobs: %v", err)
+		return "", err
+	}
+	c.logger.Infof("%d jobs and %d log files are removed, jobs: %v", len(result.JobIDs), result.LogsRemoved, result.JobIDs)
+	return models.JobContinue, nil
+}
+
+// Exit ...
+func (c *Cleaner) Exit() error {
+	return nil
+}
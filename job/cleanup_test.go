@@ -0,0 +1,109 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package job
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmware/harbor/models"
+)
+
+func TestOutOfRetention(t *testing.T) {
+	now := time.Now()
+	job := func(id, policyID int64, status string, daysAgo int) *models.RepJob {
+		return &models.RepJob{
+			ID:         id,
+			PolicyID:   policyID,
+			Status:     status,
+			UpdateTime: now.AddDate(0, 0, -daysAgo),
+		}
+	}
+	// ordered by policy and from the latest to the earliest
+	jobs := []*models.RepJob{
+		job(6, 1, models.JobFinished, 1),
+		job(5, 1, models.JobError, 2),
+		job(4, 1, models.JobFinished, 5),
+		job(3, 1, models.JobError, 10),
+		job(2, 1, models.JobStopped, 20),
+		job(8, 2, models.JobFinished, 3),
+		job(7, 2, models.JobFinished, 30),
+	}
+
+	cases := []struct {
+		days    int
+		count   int
+		removed []int64
+	}{
+		{0, 0, nil},
+		// the latest failed job 5 is kept
+		{3, 0, []int64{4, 3, 2, 7}},
+		{1, 0, []int64{4, 3, 2, 8, 7}},
+		{0, 1, []int64{4, 3, 2, 7}},
+		// the kept failed job is counted
+		{0, 3, []int64{3, 2}},
+		{0, 10, nil},
+		// either the age or the count exceeds the retention
+		{15, 2, []int64{4, 3, 2, 7}},
+		{100, 4, []int64{2}},
+	}
+
+	for _, c := range cases {
+		var removed []int64
+		for _, j := range outOfRetention(jobs, c.days, c.count, now) {
+			removed = append(removed, j.ID)
+		}
+		if !reflect.DeepEqual(removed, c.removed) {
+			t.Errorf("unexpected jobs removed for %d days, %d jobs: %v, expected: %v", c.days, c.count, removed, c.removed)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	n := func(i int) *int {
+		return &i
+	}
+
+	cases := []struct {
+		days  *int
+		count *int
+		d     int
+		c     int
+		err   bool
+	}{
+		// no retention is configured in the tests
+		{nil, nil, 0, 0, false},
+		{n(3), nil, 3, 0, false},
+		{nil, n(10), 0, 10, false},
+		// 0 disables the limit explicitly
+		{n(0), n(10), 0, 10, false},
+		{n(-1), nil, 0, 0, true},
+		{n(3), n(-1), 0, 0, true},
+	}
+
+	for i, c := range cases {
+		days, count, err := Retention(c.days, c.count)
+		if (err != nil) != c.err {
+			t.Errorf("unexpected error of case %d: %v", i, err)
+			continue
+		}
+		if days != c.d || count != c.c {
+			t.Errorf("unexpected retention of case %d: %d days, %d jobs, expected: %d days, %d jobs",
+				i, days, count, c.d, c.c)
+		}
+	}
+}
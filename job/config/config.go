@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/astaxie/beego"
 	"github.com/vmware/harbor/utils/log"
@@ -26,12 +27,40 @@ import (
 
 const defaultMaxWorkers int = 10
 
+const defaultMaxBlobTransfers int = 3
+
+const defaultBlobChunkSize int64 = 10 * 1024 * 1024
+
+// the retry settings are global rather than per policy: the retries ride out the failures of
+// registries and networks, which are shared by all the policies of a job service
+const (
+	defaultRetryInitialDelay = 5 * time.Minute
+	defaultRetryMultiplier   = 2.0
+	defaultRetryMaxDelay     = 1 * time.Hour
+	defaultRetryMaxAttempts  = 10
+)
+
+const defaultShutdownTimeout = 1 * time.Minute
+
+const defaultJobCleanupInterval = 1 * time.Hour
+
 var maxJobWorkers int
+var maxBlobTransfers int
+var blobChunkSize int64
 var localUIURL string
 var localRegURL string
 var logDir string
 var uiSecret string
 var verifyRemoteCert string
+var retryInitialDelay time.Duration
+var retryMultiplier float64
+var retryMaxDelay time.Duration
+var retryMaxAttempts int
+var nodeID string
+var shutdownTimeout time.Duration
+var jobRetentionDays int
+var jobRetentionCount int
+var jobCleanupInterval time.Duration
 
 func init() {
 	maxWorkersEnv := os.Getenv("MAX_JOB_WORKERS")
@@ -42,6 +71,26 @@ func init() {
 		maxJobWorkers = defaultMaxWorkers
 	}
 
+	maxBlobTransfers = defaultMaxBlobTransfers
+	if s := os.Getenv("MAX_BLOB_TRANSFERS"); len(s) != 0 {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			log.Warningf("Invalid max blob transfers setting: %s, the default value: %d will be used", s, defaultMaxBlobTransfers)
+		} else {
+			maxBlobTransfers = n
+		}
+	}
+
+	blobChunkSize = defaultBlobChunkSize
+	if s := os.Getenv("BLOB_CHUNK_SIZE"); len(s) != 0 {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			log.Warningf("Invalid blob chunk size setting: %s, the default value: %d will be used", s, defaultBlobChunkSize)
+		} else {
+			blobChunkSize = n
+		}
+	}
+
 	localRegURL = os.Getenv("REGISTRY_URL")
 	if len(localRegURL) == 0 {
 		localRegURL = "http://registry:5000"
@@ -52,6 +101,8 @@ func init() {
 		localUIURL = "http://ui"
 	}
 
+	// the logs of a job are written on the node running it but may be read via any node,
+	// so the directory must be shared when several job service nodes are deployed
 	logDir = os.Getenv("LOG_DIR")
 	if len(logDir) == 0 {
 		logDir = "/var/log"
@@ -80,6 +131,45 @@ func init() {
 		verifyRemoteCert = "on"
 	}
 
+	retryInitialDelay = parseDurationEnv("RETRY_INITIAL_DELAY", defaultRetryInitialDelay)
+	retryMaxDelay = parseDurationEnv("RETRY_MAX_DELAY", defaultRetryMaxDelay)
+	retryMultiplier = defaultRetryMultiplier
+	if s := os.Getenv("RETRY_MULTIPLIER"); len(s) != 0 {
+		m, err := strconv.ParseFloat(s, 64)
+		if err != nil || m < 1 {
+			log.Warningf("Invalid retry multiplier: %s, the default value: %v will be used", s, defaultRetryMultiplier)
+		} else {
+			retryMultiplier = m
+		}
+	}
+	retryMaxAttempts = defaultRetryMaxAttempts
+	if s := os.Getenv("RETRY_MAX_ATTEMPTS"); len(s) != 0 {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			log.Warningf("Invalid max retry attempts: %s, the default value: %d will be used", s, defaultRetryMaxAttempts)
+		} else {
+			retryMaxAttempts = n
+		}
+	}
+
+	shutdownTimeout = parseDurationEnv("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+
+	jobRetentionDays = parseIntEnv("JOB_RETENTION_DAYS")
+	jobRetentionCount = parseIntEnv("JOB_RETENTION_COUNT")
+	jobCleanupInterval = parseDurationEnv("JOB_CLEANUP_INTERVAL", defaultJobCleanupInterval)
+	if jobCleanupInterval <= 0 {
+		jobCleanupInterval = defaultJobCleanupInterval
+	}
+
+	nodeID = os.Getenv("NODE_ID")
+	if len(nodeID) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "jobservice"
+		}
+		nodeID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
 	configPath := os.Getenv("CONFIG_PATH")
 	if len(configPath) != 0 {
 		log.Infof("Config path: %s", configPath)
@@ -87,10 +177,18 @@ func init() {
 	}
 
 	log.Debugf("config: maxJobWorkers: %d", maxJobWorkers)
+	log.Debugf("config: maxBlobTransfers: %d", maxBlobTransfers)
+	log.Debugf("config: blobChunkSize: %d", blobChunkSize)
 	log.Debugf("config: localUIURL: %s", localUIURL)
 	log.Debugf("config: localRegURL: %s", localRegURL)
 	log.Debugf("config: verifyRemoteCert: %s", verifyRemoteCert)
 	log.Debugf("config: logDir: %s", logDir)
+	log.Debugf("config: retryInitialDelay: %v, retryMultiplier: %v, retryMaxDelay: %v, retryMaxAttempts: %d",
+		retryInitialDelay, retryMultiplier, retryMaxDelay, retryMaxAttempts)
+	log.Debugf("config: nodeID: %s", nodeID)
+	log.Debugf("config: shutdownTimeout: %v", shutdownTimeout)
+	log.Debugf("config: jobRetentionDays: %d, jobRetentionCount: %d, jobCleanupInterval: %v",
+		jobRetentionDays, jobRetentionCount, jobCleanupInterval)
 	log.Debugf("config: uiSecret: ******")
 }
 
@@ -99,6 +197,16 @@ func MaxJobWorkers() int {
 	return maxJobWorkers
 }
 
+// MaxBlobTransfers returns the max number of blobs which are transferred concurrently in one job
+func MaxBlobTransfers() int {
+	return maxBlobTransfers
+}
+
+// BlobChunkSize returns the size in bytes of chunks in which blobs are uploaded to the target registry
+func BlobChunkSize() int64 {
+	return blobChunkSize
+}
+
 // LocalUIURL returns the local ui url, job service will use this URL to call API hosted on ui process
 func LocalUIURL() string {
 	return localUIURL
@@ -123,3 +231,80 @@ func UISecret() string {
 func VerifyRemoteCert() bool {
 	return verifyRemoteCert != "off"
 }
+
+// RetryInitialDelay returns the delay before the first retry of a job
+func RetryInitialDelay() time.Duration {
+	return retryInitialDelay
+}
+
+// RetryMultiplier returns the factor by which the delay grows after each retry
+func RetryMultiplier() float64 {
+	return retryMultiplier
+}
+
+// RetryMaxDelay returns the upper bound of the delay between retries
+func RetryMaxDelay() time.Duration {
+	return retryMaxDelay
+}
+
+// RetryMaxAttempts returns how many times a job can be retried before it's marked as error, 0 means no limit
+func RetryMaxAttempts() int {
+	return retryMaxAttempts
+}
+
+// NodeID returns the identity of this job service instance, the jobs claimed by the instance are owned by it
+func NodeID() string {
+	return nodeID
+}
+
+// ShutdownTimeout returns how long the job service waits for the running jobs to reach a checkpoint when it is shutting down
+func ShutdownTimeout() time.Duration {
+	return shutdownTimeout
+}
+
+// JobRetentionDays returns how many days the ended jobs are kept, 0 means no limit
+func JobRetentionDays() int {
+	return jobRetentionDays
+}
+
+// JobRetentionCount returns how many ended jobs are kept for each policy, 0 means no limit
+func JobRetentionCount() int {
+	return jobRetentionCount
+}
+
+// JobCleanupInterval returns the interval to remove the jobs which are out of retention
+func JobCleanupInterval() time.Duration {
+	return jobCleanupInterval
+}
+
+// parseIntEnv reads a non-negative integer from the environment variable, 0 is returned if it is not set or invalid
+func parseIntEnv(key string) int {
+	s := os.Getenv(key)
+	if len(s) == 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		log.Warningf("Invalid value of %s: %s, it will be ignored", key, s)
+		return 0
+	}
+	return n
+}
+
+// parseDurationEnv reads a duration such as "30s" or "5m" from the environment variable,
+// a plain number is treated as seconds.
+func parseDurationEnv(key string, def time.Duration) time.Duration {
+	s := os.Getenv(key)
+	if len(s) == 0 {
+		return def
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		log.Warningf("Invalid value of %s: %s, the default value: %v will be used", key, s, def)
+		return def
+	}
+	return d
+}
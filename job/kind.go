@@ -0,0 +1,136 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/models"
+)
+
+// Kind describes a kind of jobs handled by the job service. The jobs of all the kinds are queued in DB
+// and handled by the same worker pool, so they share the leases, retries, checkpoints and logs. The kind
+// of a job is identified by its operation.
+type Kind struct {
+	// Name is the operation of the jobs of this kind
+	Name string
+	// Validate checks the job before it's put into the queue, it's optional
+	Validate func(job *models.RepJob) error
+	// Load loads the parameters of the job into the state machine, the job will be canceled
+	// if it returns false, e.g. the policy of the job has been disabled.
+	Load func(sm *SM, job *models.RepJob) (bool, error)
+	// AddTransitions adds the transitions of the job, which start from models.JobRunning
+	// and end with models.JobFinished
+	AddTransitions func(sm *SM)
+}
+
+var kinds = make(map[string]*Kind)
+
+// RegisterKind registers a kind of jobs, it's not safe for concurrent use and should be
+// called in init functions.
+func RegisterKind(k *Kind) {
+	if _, ok := kinds[k.Name]; ok {
+		panic(fmt.Sprintf("job kind %s is already registered", k.Name))
+	}
+	kinds[k.Name] = k
+}
+
+// GetKind returns the kind registered with the name, it returns nil if the kind is not registered
+func GetKind(name string) *Kind {
+	return kinds[name]
+}
+
+// Kinds returns the names of all the registered kinds
+func Kinds() []string {
+	var names []string
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SubmitJob validates the job according to its kind and puts it into the queue
+func SubmitJob(j models.RepJob) (int64, error) {
+	kind := GetKind(j.Operation)
+	if kind == nil {
+		return 0, fmt.Errorf("unsupported operation: %s", j.Operation)
+	}
+	if kind.Validate != nil {
+		if err := kind.Validate(&j); err != nil {
+			return 0, err
+		}
+	}
+	return AddRepJob(j)
+}
+
+func init() {
+	RegisterKind(&Kind{
+		Name:           models.RepOpTransfer,
+		Validate:       validateRepJob,
+		Load:           loadRepJobParms,
+		AddTransitions: addImgTransferTransition,
+	})
+	RegisterKind(&Kind{
+		Name:           models.RepOpDelete,
+		Validate:       validateRepJob,
+		Load:           loadRepJobParms,
+		AddTransitions: addImgDeleteTransition,
+	})
+	for _, op := range []string{models.RepOpVerify, models.RepOpRepair, models.RepOpPrune} {
+		RegisterKind(&Kind{
+			Name:           op,
+			Validate:       validateRepJob,
+			Load:           loadRepJobParms,
+			AddTransitions: addVerifyTransition,
+		})
+	}
+	RegisterKind(&Kind{
+		Name:           models.RepOpPlan,
+		Validate:       validateRepJob,
+		Load:           loadRepJobParms,
+		AddTransitions: addPlanTransition,
+	})
+	RegisterKind(&Kind{
+		Name:           models.JobOpCleanup,
+		Validate:       validateCleanupJob,
+		Load:           loadCleanupJobParms,
+		AddTransitions: addCleanupTransition,
+	})
+}
+
+// validateRepJob checks the policy of the replication job exists and the repository is set
+// if the job handles a single repository
+func validateRepJob(job *models.RepJob) error {
+	if (job.Operation == models.RepOpTransfer || job.Operation == models.RepOpDelete) &&
+		len(job.Repository) == 0 {
+		return fmt.Errorf("repository is required for %s jobs", job.Operation)
+	}
+	policy, err := dao.GetRepPolicy(job.PolicyID)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		return fmt.Errorf("policy not found, id: %d", job.PolicyID)
+	}
+	// tags can only be pruned from the target
+	if job.Operation == models.RepOpPrune && policy.Direction == models.RepDirectionPull {
+		return fmt.Errorf("%s jobs are not supported by pull policies", job.Operation)
+	}
+	return nil
+}
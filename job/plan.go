@@ -0,0 +1,160 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package job
+
+import (
+	"strings"
+
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/job/config"
+	"github.com/vmware/harbor/job/replication"
+	"github.com/vmware/harbor/job/utils"
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/log"
+)
+
+// StatePlan is the state in which the plan jobs dry run their policies
+const StatePlan = "plan"
+
+func addPlanTransition(sm *SM) {
+	planner := &PolicyPlanner{
+		jobID:  sm.JobID,
+		parms:  sm.Parms,
+		logger: sm.Logger,
+	}
+	sm.AddTransition(models.JobRunning, StatePlan, planner)
+	sm.AddTransition(StatePlan, models.JobFinished, &StatusUpdater{sm.JobID, models.JobFinished})
+}
+
+// PolicyPlanner is the state handler of plan jobs, it dry runs the policy of the job and records
+// what the replication would do: the repositories and tags to be transferred, the tags already up
+// to date or conflicting, the blobs missing on the destination registry, the projects to be created
+// and, in mirror mode, the tags to be pruned. Nothing is pushed or deleted.
+type PolicyPlanner struct {
+	jobID  int64
+	parms  *RepJobParm
+	logger *log.Logger
+}
+
+// Enter plans the policy and records the plan
+func (p *PolicyPlanner) Enter() (string, error) {
+	plan, err := p.plan()
+	if err != nil {
+		p.logger.Errorf("failed to plan policy %d: %v", p.parms.Policy.ID, err)
+		return "", err
+	}
+	if err = dao.AddRepPlan(p.jobID, plan); err != nil {
+		p.logger.Errorf("failed to record the plan of policy %d: %v", p.parms.Policy.ID, err)
+		return "", err
+	}
+	p.logger.Infof("plan of policy %d completed: %d tags to transfer, %d up to date, %d skipped, %d conflicting",
+		p.parms.Policy.ID, plan.TagsToTransfer, plan.TagsUpToDate, plan.TagsSkipped, plan.TagsConflicting)
+	return models.JobContinue, nil
+}
+
+// Exit ...
+func (p *PolicyPlanner) Exit() error {
+	return nil
+}
+
+func (p *PolicyPlanner) plan() (*models.RepPlan, error) {
+	policy := p.parms.Policy
+	repoList, err := getPolicyRepoList(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &models.RepPlan{
+		PolicyID:         policy.ID,
+		Direction:        policy.Direction,
+		ProjectsToCreate: []string{},
+		Repositories:     []*models.RepPlanRepository{},
+	}
+	projects := make(map[string]bool)
+	blobs := make(map[string]bool)
+
+	for _, repo := range repoList {
+		// the job ID is not passed to the handlers, as nothing is recorded for the plan job by them
+		base := replication.InitBaseHandler(0, policy, repo, p.parms.LocalRegURL, config.UISecret(),
+			p.parms.TargetURL, p.parms.TargetUsername, p.parms.TargetPassword, p.parms.TargetType, 0,
+			p.parms.Insecure, nil, config.MaxBlobTransfers(), config.BlobChunkSize(), p.logger)
+		planner := &replication.Planner{BaseHandler: base}
+
+		repoPlan, err := planner.Plan()
+		if err != nil {
+			return nil, err
+		}
+		plan.Repositories = append(plan.Repositories, repoPlan)
+
+		for _, tag := range repoPlan.Tags {
+			switch {
+			case tag.UpToDate:
+				plan.TagsUpToDate++
+				continue
+			case tag.Conflict == models.TagResultSkipped:
+				plan.TagsSkipped++
+				continue
+			case tag.Conflict == models.TagResultConflict:
+				plan.TagsConflicting++
+				continue
+			}
+			plan.TagsToTransfer++
+			for _, blob := range tag.MissingBlobs {
+				if blobs[blob.Digest] {
+					continue
+				}
+				blobs[blob.Digest] = true
+				plan.BlobsMissing++
+				plan.BytesMissing += blob.Size
+			}
+		}
+
+		// the project on the destination
+		project := repo[:strings.LastIndex(repo, "/")]
+		if policy.Direction != models.RepDirectionPull {
+			project = policy.RemoteProject(project)
+		}
+		if _, ok := projects[project]; ok {
+			continue
+		}
+		missing, err := planner.ProjectMissing()
+		if err != nil {
+			return nil, err
+		}
+		projects[project] = missing
+		if missing {
+			plan.ProjectsToCreate = append(plan.ProjectsToCreate, project)
+		}
+	}
+
+	// the same as SyncPolicy, the tags removed at the source are pruned from the target in mirror mode
+	if policy.Mirror == 1 && policy.Direction != models.RepDirectionPull {
+		localRepos := func() ([]string, error) {
+			return utils.GetRepoList(policy.ProjectID)
+		}
+		remoteRepos := func() ([]string, error) {
+			return getRemoteRepoList(policy)
+		}
+		verifier := replication.NewVerifier(0, policy, p.parms.LocalRegURL, config.UISecret(),
+			p.parms.TargetURL, p.parms.TargetUsername, p.parms.TargetPassword, p.parms.Insecure,
+			config.MaxBlobTransfers(), false, true, localRepos, remoteRepos, nil, p.logger)
+		if plan.Prune, err = verifier.PlanPrune(); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,92 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package job
+
+import (
+	"time"
+
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/cron"
+	"github.com/vmware/harbor/utils/log"
+)
+
+// the interval to check whether there are policies need to be triggered,
+// as the minimum unit of cron string is minute, one minute is enough
+const policyCheckInterval = 1 * time.Minute
+
+// SchedulePolicies is a loop which triggers full replications of the enabled policies
+// according to their cron strings. The next run time is persisted in DB, so the schedule
+// survives restarts of job service, and the missed run will be triggered once after restarting.
+// It runs on every node, a run is triggered by the node which claims it in DB.
+func SchedulePolicies() {
+	for {
+		checkScheduledPolicies(time.Now())
+		time.Sleep(policyCheckInterval)
+	}
+}
+
+func checkScheduledPolicies(now time.Time) {
+	policies, err := dao.GetScheduledRepPolicies()
+	if err != nil {
+		log.Errorf("Failed to get scheduled policies, error: %v", err)
+		return
+	}
+
+	for _, policy := range policies {
+		schedule, err := cron.Parse(policy.CronStr)
+		if err != nil {
+			log.Warningf("Invalid cron string of policy %d: %s, error: %v", policy.ID, policy.CronStr, err)
+			continue
+		}
+
+		// the policy is new, re-enabled or its cron string has been modified,
+		// calculate the next run time only
+		if policy.NextRunTime.IsZero() {
+			from := now
+			if policy.StartTime.After(now) {
+				from = policy.StartTime
+			}
+			next := schedule.Next(from)
+			log.Debugf("Next run time of policy %d: %v", policy.ID, next)
+			if err := dao.UpdateRepPolicySchedule(policy.ID, policy.LastRunTime, next); err != nil {
+				log.Errorf("Failed to update schedule of policy %d, error: %v", policy.ID, err)
+			}
+			continue
+		}
+
+		if policy.NextRunTime.After(now) {
+			continue
+		}
+
+		next := schedule.Next(now)
+		claimed, err := dao.ClaimRepPolicySchedule(policy.ID, policy.NextRunTime, now, next)
+		if err != nil {
+			log.Errorf("Failed to update schedule of policy %d, error: %v", policy.ID, err)
+			continue
+		}
+		if !claimed {
+			log.Debugf("The run of policy %d at %v has been claimed by another node", policy.ID, policy.NextRunTime)
+			continue
+		}
+		log.Debugf("Next run time of policy %d: %v", policy.ID, next)
+
+		log.Infof("Triggering scheduled replication of policy %d, cron: %s", policy.ID, policy.CronStr)
+		if _, err := SyncPolicy(policy, models.RepTriggerSchedule); err != nil {
+			log.Errorf("Failed to trigger scheduled replication of policy %d, error: %v", policy.ID, err)
+		}
+	}
+}
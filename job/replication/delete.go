@@ -16,17 +16,18 @@
 package replication
 
 import (
-	"errors"
-
-	"github.com/vmware/harbor/models"
-	"github.com/vmware/harbor/utils/log"
-	//"github.com/vmware/harbor/utils/registry"
-	//"github.com/vmware/harbor/utils/registry/auth"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/log"
+	"github.com/vmware/harbor/utils/registry"
+	"github.com/vmware/harbor/utils/registry/auth"
+	registry_error "github.com/vmware/harbor/utils/registry/error"
 )
 
 const (
@@ -38,32 +39,47 @@ var (
 	errNotFound = errors.New("Not Found")
 )
 
-// Deleter deletes repository or tags
+// Deleter deletes repository or tags. If no tag is specified, the repository is deleted, unless the
+// policy has filters on tags, in which case only the tags matching the filters are deleted.
 type Deleter struct {
+	policy     *models.RepPolicy
 	repository string // prject_name/repo_name
 	tags       []string
 
+	srcURL        string // url of local registry
+	srcSecret     string // the secret used to access local registry
+	srcRepository string // the name of the repository in local registry
+
 	dstURL string // url of target registry
 	dstUsr string // username ...
 	dstPwd string // username ...
 
+	targetType int // harbor or registry
+
 	insecure bool
 
-	//dstClient *registry.Repository
+	srcClient *registry.Repository
+	dstClient *registry.Repository
 
 	logger *log.Logger
 }
 
 // NewDeleter returns a Deleter
-func NewDeleter(repository string, tags []string, dstURL, dstUsr, dstPwd string, insecure bool, logger *log.Logger) *Deleter {
+func NewDeleter(policy *models.RepPolicy, repository string, tags []string, srcURL, srcSecret, srcRepository,
+	dstURL, dstUsr, dstPwd string, targetType int, insecure bool, logger *log.Logger) *Deleter {
 	deleter := &Deleter{
-		repository: repository,
-		tags:       tags,
-		dstURL:     dstURL,
-		dstUsr:     dstUsr,
-		dstPwd:     dstPwd,
-		insecure:   insecure,
-		logger:     logger,
+		policy:        policy,
+		repository:    repository,
+		tags:          tags,
+		srcURL:        srcURL,
+		srcSecret:     srcSecret,
+		srcRepository: srcRepository,
+		dstURL:        dstURL,
+		dstUsr:        dstUsr,
+		dstPwd:        dstPwd,
+		targetType:    targetType,
+		insecure:      insecure,
+		logger:        logger,
 	}
 	deleter.logger.Infof("initialization completed: repository: %s, tags: %v, destination URL: %s, insecure: %v, destination user: %s",
 		deleter.repository, deleter.tags, deleter.dstURL, deleter.insecure, deleter.dstUsr)
@@ -87,6 +103,26 @@ func (d *Deleter) Enter() (string, error) {
 }
 
 func (d *Deleter) enter() (string, error) {
+	// the tags excluded by the filters of the policy are kept
+	if len(d.tags) == 0 && d.policy.HasTagFilter() {
+		tags, err := d.listTags()
+		if err != nil {
+			if err == errNotFound {
+				d.logger.Warningf("repository %s does not exist on %s", d.repository, d.dstURL)
+				return models.JobFinished, nil
+			}
+			return "", err
+		}
+		if d.tags = d.policy.FilterTags(tags); len(d.tags) == 0 {
+			d.logger.Infof("no tag of repository %s on %s matches the filters of policy %d", d.repository, d.dstURL, d.policy.ID)
+			return models.JobFinished, nil
+		}
+	}
+
+	if d.targetType == models.RepTargetTypeRegistry {
+		return d.enterRegistry()
+	}
+
 	url := strings.TrimRight(d.dstURL, "/") + "/api/repositories/"
 
 	// delete repository
@@ -123,43 +159,177 @@ func (d *Deleter) enter() (string, error) {
 		d.logger.Infof("repository %s:%s on %s has been deleted", d.repository, tag, d.dstURL)
 	}
 	return models.JobFinished, nil
+}
 
-	/*
-		// the follow codes can be used for non-harbor repository deletion
-		dstCred := auth.NewBasicAuthCredential(d.dstUsr, d.dstPwd)
-		dstClient, err := newRepositoryClient(d.dstURL, d.insecure, dstCred,
-			d.repository, "repository", d.repository, "pull", "push", "*")
+// enterRegistry deletes the tags via registry API for the non-harbor target,
+// all the tags are deleted if the repository is deleted.
+func (d *Deleter) enterRegistry() (string, error) {
+	if err := d.initClient(); err != nil {
+		return "", err
+	}
+
+	var kept map[string]bool
+	if len(d.tags) == 0 {
+		tags, err := d.listTags()
 		if err != nil {
-			d.logger.Errorf("an error occurred while creating destination repository client: %v", err)
+			if err == errNotFound {
+				d.logger.Warningf("repository %s does not exist on %s", d.repository, d.dstURL)
+				return models.JobFinished, nil
+			}
 			return "", err
 		}
 
-		d.dstClient = dstClient
+		d.tags = append(d.tags, tags...)
+	} else {
+		var err error
+		if kept, err = d.keptDigests(); err != nil {
+			if err == errNotFound {
+				d.logger.Warningf("repository %s does not exist on %s", d.repository, d.dstURL)
+				return models.JobFinished, nil
+			}
+			return "", err
+		}
+	}
+
+	d.logger.Infof("tags %v will be deleted", d.tags)
 
-		if len(d.tags) == 0 {
-			tags, err := d.dstClient.ListTag()
-			if err != nil {
-				d.logger.Errorf("an error occurred while listing tags of repository %s on %s with user %s: %v", d.repository, d.dstURL, d.dstUsr, err)
-				return "", err
+	for _, tag := range d.tags {
+		digest, exist, err := d.dstClient.ManifestExist(tag)
+		if err != nil {
+			d.logger.Errorf("an error occurred while checking the existence of manifest of %s:%s on %s: %v", d.repository, tag, d.dstURL, err)
+			return "", err
+		}
+		if !exist {
+			d.logger.Warningf("repository %s:%s does not exist on %s", d.repository, tag, d.dstURL)
+			continue
+		}
+		if kept[digest] {
+			d.logger.Warningf("%s:%s on %s shares the digest %s with the tags which still exist at the source, skip",
+				d.repository, tag, d.dstURL, digest)
+			continue
+		}
+
+		if err := d.dstClient.DeleteManifest(digest); err != nil {
+			if regErr, ok := err.(*registry_error.Error); ok && regErr.StatusCode == http.StatusNotFound {
+				d.logger.Warningf("repository %s:%s does not exist on %s", d.repository, tag, d.dstURL)
+				continue
 			}
+			d.logger.Errorf("an error occurred while deleting repository %s:%s on %s with user %s: %v", d.repository, tag, d.dstURL, d.dstUsr, err)
+			return "", err
+		}
+
+		d.logger.Infof("repository %s:%s on %s has been deleted", d.repository, tag, d.dstURL)
+	}
+
+	return models.JobFinished, nil
+}
+
+// initClient creates the client of the repository on the target registry if it hasn't been created
+func (d *Deleter) initClient() error {
+	if d.dstClient != nil {
+		return nil
+	}
+
+	dstCred := auth.NewBasicAuthCredential(d.dstUsr, d.dstPwd)
+	dstClient, err := newRepositoryClient(d.dstURL, d.insecure, dstCred,
+		d.repository, "repository", d.repository, "pull", "push", "*")
+	if err != nil {
+		d.logger.Errorf("an error occurred while creating destination repository client: %v", err)
+		return err
+	}
+
+	d.dstClient = dstClient
+	return nil
+}
+
+// keptDigests returns the digests which can't be deleted from the regular registry. A tag is deleted
+// by its digest there, which removes all the tags sharing the digest, so the digests of the tags which
+// are not to be deleted and still exist at the source are kept.
+func (d *Deleter) keptDigests() (map[string]bool, error) {
+	dstTags, err := d.listTags()
+	if err != nil {
+		return nil, err
+	}
+	srcTags, err := d.listSrcTags()
+	if err != nil {
+		return nil, err
+	}
 
-			d.tags = append(d.tags, tags...)
+	kept := make(map[string]bool)
+	for _, tag := range retainedTags(dstTags, srcTags, d.tags) {
+		digest, exist, err := d.dstClient.ManifestExist(tag)
+		if err != nil {
+			d.logger.Errorf("an error occurred while checking the existence of manifest of %s:%s on %s: %v", d.repository, tag, d.dstURL, err)
+			return nil, err
+		}
+		if exist {
+			kept[digest] = true
 		}
+	}
+	return kept, nil
+}
 
-		d.logger.Infof("tags %v will be deleted", d.tags)
+// retainedTags returns the tags on the destination which are not to be deleted and still exist at the source
+func retainedTags(dstTags, srcTags, tags []string) []string {
+	deleted := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		deleted[tag] = true
+	}
+	src := make(map[string]bool, len(srcTags))
+	for _, tag := range srcTags {
+		src[tag] = true
+	}
 
-		for _, tag := range d.tags {
+	var retained []string
+	for _, tag := range dstTags {
+		if !deleted[tag] && src[tag] {
+			retained = append(retained, tag)
+		}
+	}
+	return retained
+}
 
-			if err := d.dstClient.DeleteTag(tag); err != nil {
-				d.logger.Errorf("an error occurred while deleting repository %s:%s on %s with user %s: %v", d.repository, tag, d.dstURL, d.dstUsr, err)
-				return "", err
-			}
+// listSrcTags lists the tags of the repository in local registry, nothing is returned
+// if the repository doesn't exist
+func (d *Deleter) listSrcTags() ([]string, error) {
+	if d.srcClient == nil {
+		srcCred := auth.NewCookieCredential(&http.Cookie{Name: models.UISecretCookie, Value: d.srcSecret})
+		srcClient, err := newRepositoryClient(d.srcURL, d.insecure, srcCred,
+			d.srcRepository, "repository", d.srcRepository, "pull")
+		if err != nil {
+			d.logger.Errorf("an error occurred while creating source repository client: %v", err)
+			return nil, err
+		}
+		d.srcClient = srcClient
+	}
 
-			d.logger.Infof("repository %s:%s on %s has been deleted", d.repository, tag, d.dstURL)
+	tags, err := d.srcClient.ListTag()
+	if err != nil {
+		if regErr, ok := err.(*registry_error.Error); ok && regErr.StatusCode == http.StatusNotFound {
+			return nil, nil
 		}
+		d.logger.Errorf("an error occurred while listing tags of repository %s on %s: %v", d.srcRepository, d.srcURL, err)
+		return nil, err
+	}
+	return tags, nil
+}
 
-		return models.JobFinished, nil
-	*/
+// listTags lists the tags of the repository on the target registry, it returns errNotFound
+// if the repository doesn't exist
+func (d *Deleter) listTags() ([]string, error) {
+	if err := d.initClient(); err != nil {
+		return nil, err
+	}
+
+	tags, err := d.dstClient.ListTag()
+	if err != nil {
+		if regErr, ok := err.(*registry_error.Error); ok && regErr.StatusCode == http.StatusNotFound {
+			return nil, errNotFound
+		}
+		d.logger.Errorf("an error occurred while listing tags of repository %s on %s with user %s: %v", d.repository, d.dstURL, d.dstUsr, err)
+		return nil, err
+	}
+	return tags, nil
 }
 
 func del(url, username, password string, insecure bool) error {
@@ -0,0 +1,46 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package replication
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRetainedTags(t *testing.T) {
+	cases := []struct {
+		dstTags  []string
+		srcTags  []string
+		tags     []string
+		retained []string
+	}{
+		{nil, nil, []string{"v1"}, nil},
+		// v2 is removed at the source too
+		{[]string{"v1", "v2"}, nil, []string{"v1"}, nil},
+		{[]string{"v1", "v2", "latest"}, []string{"v2", "latest"}, []string{"v1"}, []string{"v2", "latest"}},
+		// the tags to be deleted are not retained even if they exist at the source
+		{[]string{"v1", "v2"}, []string{"v1", "v2"}, []string{"v1"}, []string{"v2"}},
+		// only the tags on the destination are retained
+		{[]string{"v1"}, []string{"v2"}, []string{"v1"}, nil},
+	}
+
+	for i, c := range cases {
+		retained := retainedTags(c.dstTags, c.srcTags, c.tags)
+		if !reflect.DeepEqual(retained, c.retained) {
+			t.Errorf("unexpected retained tags of case %d: %v, expected: %v", i, retained, c.retained)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package replication
+
+import (
+	"sync"
+)
+
+// the max number of blobs whose locations are remembered, the cache is
+// cleared when it is full to bound the memory used
+const maxBlobLocations = 10000
+
+// blobLocations remembers the repository on the destination registry which is known to
+// hold a blob, it's shared by all the jobs so that a blob uploaded by one job can be
+// mounted by others. key: destination URL + digest, value: repository
+var blobLocations = struct {
+	sync.Mutex
+	repos map[string]string
+}{
+	repos: make(map[string]string),
+}
+
+// blobHolder returns a repository other than repository on the destination registry
+// which is known to hold the blob, empty string if not found.
+func blobHolder(dstURL, repository, digest string) string {
+	blobLocations.Lock()
+	defer blobLocations.Unlock()
+	repo := blobLocations.repos[dstURL+"|"+digest]
+	if repo == repository {
+		return ""
+	}
+	return repo
+}
+
+// recordBlobHolder records that the blob exists in the repository on the destination registry
+func recordBlobHolder(dstURL, repository, digest string) {
+	blobLocations.Lock()
+	defer blobLocations.Unlock()
+	if len(blobLocations.repos) >= maxBlobLocations {
+		blobLocations.repos = make(map[string]string)
+	}
+	blobLocations.repos[dstURL+"|"+digest] = repository
+}
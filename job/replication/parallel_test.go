@@ -0,0 +1,172 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package replication
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	cases := []struct {
+		n     int
+		items []string
+	}{
+		{0, []string{"a", "b"}},
+		{1, []string{"a", "b", "c"}},
+		{3, []string{"a", "b", "c", "d", "e", "f", "g"}},
+		{10, []string{"a", "b"}},
+		{2, nil},
+	}
+
+	for _, c := range cases {
+		var lock sync.Mutex
+		running, maxRunning := 0, 0
+		handled := make(map[string]int)
+		err := parallel(c.n, c.items, func(item string) error {
+			lock.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			handled[item]++
+			lock.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			lock.Lock()
+			running--
+			lock.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Errorf("unexpected error for %d goroutines: %v", c.n, err)
+		}
+		if len(handled) != len(c.items) {
+			t.Errorf("unexpected items handled with %d goroutines: %v, expected: %v", c.n, handled, c.items)
+		}
+		for item, times := range handled {
+			if times != 1 {
+				t.Errorf("item %s is handled %d times with %d goroutines", item, times, c.n)
+			}
+		}
+		limit := c.n
+		if limit <= 0 {
+			limit = 1
+		}
+		if maxRunning > limit {
+			t.Errorf("%d goroutines run at the same time, expected at most %d", maxRunning, limit)
+		}
+	}
+}
+
+func TestParallelError(t *testing.T) {
+	errFailed := errors.New("failed")
+	var lock sync.Mutex
+	var handled []string
+	err := parallel(1, []string{"a", "b", "c", "d"}, func(item string) error {
+		lock.Lock()
+		handled = append(handled, item)
+		lock.Unlock()
+		if item == "b" {
+			return errFailed
+		}
+		return nil
+	})
+	if err != errFailed {
+		t.Errorf("unexpected error: %v, expected: %v", err, errFailed)
+	}
+	// no more item is handled once an error occurs
+	if !reflect.DeepEqual(handled, []string{"a", "b"}) {
+		t.Errorf("unexpected items handled: %v", handled)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	cases := []struct {
+		items    []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{"a"}, []string{"a"}},
+		{[]string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{[]string{"a", "b", "a", "c", "b"}, []string{"a", "b", "c"}},
+		{[]string{"b", "b", "b"}, []string{"b"}},
+	}
+
+	for _, c := range cases {
+		if result := unique(c.items); !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("unexpected result for %v: %v, expected: %v", c.items, result, c.expected)
+		}
+	}
+}
+
+func newTestBlobTransfer() *BlobTransfer {
+	return &BlobTransfer{
+		BaseHandler: &BaseHandler{
+			blobsExistence: make(map[string]bool),
+			blobsInflight:  make(map[string]chan struct{}),
+		},
+	}
+}
+
+func TestTransferBlobExisting(t *testing.T) {
+	b := newTestBlobTransfer()
+	b.blobsExistence["sha256:a"] = true
+
+	if err := b.transferBlob("sha256:a"); err != nil {
+		t.Errorf("unexpected error while transferring an existing blob: %v", err)
+	}
+	if len(b.blobsInflight) != 0 {
+		t.Errorf("an existing blob should not be transferred: %v", b.blobsInflight)
+	}
+}
+
+func TestTransferBlobInflight(t *testing.T) {
+	b := newTestBlobTransfer()
+	done := make(chan struct{})
+	b.blobsInflight["sha256:a"] = done
+
+	result := make(chan error)
+	go func() {
+		result <- b.transferBlob("sha256:a")
+	}()
+
+	select {
+	case err := <-result:
+		t.Fatalf("transferBlob returned before the transfer in flight finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the other goroutine finishes transferring the blob
+	b.blobsLock.Lock()
+	b.blobsExistence["sha256:a"] = true
+	close(done)
+	delete(b.blobsInflight, "sha256:a")
+	b.blobsLock.Unlock()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("unexpected error while waiting for the transfer in flight: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("transferBlob did not return after the transfer in flight finished")
+	}
+}
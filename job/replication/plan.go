@@ -0,0 +1,133 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package replication
+
+import (
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/models"
+)
+
+// Planner computes what the replication of a repository would do without pushing
+// anything to the destination registry, it is used to dry run a policy.
+type Planner struct {
+	*BaseHandler
+}
+
+// Plan lists the tags which would be replicated, and for each of them checks whether
+// the manifest on the destination registry is up to date and which blobs are missing.
+func (p *Planner) Plan() (*models.RepPlanRepository, error) {
+	// Initializer only creates clients and lists tags, it does not change anything
+	initializer := &Initializer{BaseHandler: p.BaseHandler}
+	if _, err := initializer.enter(); err != nil {
+		return nil, err
+	}
+
+	plan := &models.RepPlanRepository{
+		Name: p.repository,
+		Tags: []*models.RepPlanTag{},
+	}
+	for _, tag := range p.tags {
+		t, err := p.planTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		plan.Tags = append(plan.Tags, t)
+	}
+
+	return plan, nil
+}
+
+func (p *Planner) planTag(tag string) (*models.RepPlanTag, error) {
+	digest, manifest, err := p.pullManifest(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &models.RepPlanTag{
+		Tag:          tag,
+		Digest:       digest,
+		MissingBlobs: []*models.RepPlanBlob{},
+	}
+
+	dstDigest, exist, err := p.dstClient.ManifestExist(tag)
+	if err != nil {
+		p.logger.Errorf("an error occurred while checking the existence of manifest of %s:%s on %s: %v", p.repository, tag, p.dstURL, err)
+		return nil, err
+	}
+	if exist && dstDigest == digest {
+		plan.UpToDate = true
+		return plan, nil
+	}
+	// the same as checkConflict, nothing would be transferred for the tag
+	if exist && (p.policy.ConflictMode == models.ConflictSkip || p.policy.ConflictMode == models.ConflictFail) {
+		plan.Conflict = models.TagResultSkipped
+		if p.policy.ConflictMode == models.ConflictFail {
+			plan.Conflict = models.TagResultConflict
+		}
+		return plan, nil
+	}
+
+	children, err := p.pullChildren(tag, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var blobs []string
+	sizes := make(map[string]int64)
+	for _, descriptor := range blobsOf(manifest, children) {
+		blobs = append(blobs, descriptor.Digest.String())
+		sizes[descriptor.Digest.String()] = descriptor.Size
+	}
+	blobs = unique(blobs)
+
+	if err = p.checkBlobsExistence(tag, blobs); err != nil {
+		return nil, err
+	}
+
+	for _, blob := range blobs {
+		if !p.blobsExistence[blob] {
+			plan.MissingBlobs = append(plan.MissingBlobs, &models.RepPlanBlob{
+				Digest: blob,
+				Size:   sizes[blob],
+			})
+		}
+	}
+
+	return plan, nil
+}
+
+// ProjectMissing returns true if the project of the repository does not exist on
+// the destination and would be created by the replication
+func (p *Planner) ProjectMissing() (bool, error) {
+	if p.pull {
+		project, err := dao.GetProjectByName(p.project)
+		if err != nil {
+			return false, err
+		}
+		return project == nil, nil
+	}
+
+	// the regular registry has no concept of project
+	if p.remoteType == models.RepTargetTypeRegistry {
+		return false, nil
+	}
+
+	exist, err := p.remoteProjectExists()
+	if err != nil {
+		return false, err
+	}
+	return !exist, nil
+}
@@ -0,0 +1,93 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package replication
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/log"
+)
+
+// the min interval between two writes of progress to DB
+const progressFlushInterval = 5 * time.Second
+
+// progressTracker records the progress of a job and persists it into DB periodically,
+// it's shared by the goroutines transferring blobs.
+type progressTracker struct {
+	jobID     int64
+	lock      sync.Mutex
+	progress  models.RepJobProgress
+	lastFlush time.Time
+}
+
+func newProgressTracker(jobID int64) *progressTracker {
+	now := time.Now()
+	return &progressTracker{
+		jobID: jobID,
+		progress: models.RepJobProgress{
+			StartTime:  now,
+			UpdateTime: now,
+		},
+	}
+}
+
+// update modifies the progress with f, the progress is written to DB if force is
+// true or it has not been written for a while.
+func (p *progressTracker) update(force bool, f func(*models.RepJobProgress)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	f(&p.progress)
+	now := time.Now()
+	p.progress.UpdateTime = now
+	if elapsed := now.Sub(p.progress.StartTime).Seconds(); elapsed > 0 {
+		p.progress.Throughput = int64(float64(p.progress.BytesTransferred) / elapsed)
+	}
+
+	// there is no job record for dry runs
+	if p.jobID == 0 || !force && now.Sub(p.lastFlush) < progressFlushInterval {
+		return
+	}
+	p.lastFlush = now
+	progress := p.progress
+	if err := dao.UpdateRepJobProgress(p.jobID, &progress); err != nil {
+		log.Warningf("failed to update the progress of job %d: %v", p.jobID, err)
+	}
+}
+
+// reader wraps r to count the bytes read from it as transferred
+func (p *progressTracker) reader(r io.Reader) io.Reader {
+	return &progressReader{reader: r, tracker: p}
+}
+
+type progressReader struct {
+	reader  io.Reader
+	tracker *progressTracker
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	if n > 0 {
+		r.tracker.update(false, func(p *models.RepJobProgress) {
+			p.BytesTransferred += int64(n)
+		})
+	}
+	return n, err
+}
@@ -0,0 +1,112 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package replication
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// the max bytes read from a limited stream at a time, which keeps the stream smooth
+const maxLimitedRead = 32 * 1024
+
+// bandwidthLimiter is a token bucket shared by all the blob streams of the same target. It lives
+// in the memory of the process, so the limit is per job service node rather than per cluster.
+type bandwidthLimiter struct {
+	lock   sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+}
+
+var (
+	limitersLock sync.Mutex
+	// key: url of target
+	limiters = make(map[string]*bandwidthLimiter)
+)
+
+// getBandwidthLimiter returns the limiter of the target, the limit is in KB per second.
+// It returns nil if the bandwidth is unlimited.
+func getBandwidthLimiter(target string, limit int64) *bandwidthLimiter {
+	if limit <= 0 {
+		return nil
+	}
+
+	limitersLock.Lock()
+	defer limitersLock.Unlock()
+
+	l, ok := limiters[target]
+	if !ok {
+		l = &bandwidthLimiter{
+			last: time.Now(),
+		}
+		limiters[target] = l
+	}
+
+	// the limit may be modified since the limiter was created
+	l.lock.Lock()
+	l.rate = float64(limit * 1024)
+	l.lock.Unlock()
+
+	return l
+}
+
+// wait blocks until n bytes are allowed to be transferred. The tokens can be
+// borrowed, so that the concurrent streams take turns rather than starve.
+func (l *bandwidthLimiter) wait(n int) {
+	l.lock.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	// allow a burst of at most one second
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.lock.Unlock()
+
+	time.Sleep(d)
+}
+
+// reader wraps r to limit the rate of reading from it, r is returned directly if l is nil
+func (l *bandwidthLimiter) reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{reader: r, limiter: l}
+}
+
+type limitedReader struct {
+	reader  io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (r *limitedReader) Read(b []byte) (int, error) {
+	if len(b) > maxLimitedRead {
+		b = b[:maxLimitedRead]
+	}
+	n, err := r.reader.Read(b)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
@@ -0,0 +1,96 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package replication
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestGetBandwidthLimiter(t *testing.T) {
+	cases := []struct {
+		target string
+		limit  int64
+		rate   float64 // 0 means no limiter
+	}{
+		{"http://unlimited", 0, 0},
+		{"http://unlimited", -1, 0},
+		{"http://limited", 100, 100 * 1024},
+		// the limit of the target is modified
+		{"http://limited", 200, 200 * 1024},
+	}
+
+	var last *bandwidthLimiter
+	for _, c := range cases {
+		l := getBandwidthLimiter(c.target, c.limit)
+		if c.rate == 0 {
+			if l != nil {
+				t.Errorf("unexpected limiter for %s with limit %d", c.target, c.limit)
+			}
+			continue
+		}
+		if l == nil {
+			t.Errorf("no limiter for %s with limit %d", c.target, c.limit)
+			continue
+		}
+		if l.rate != c.rate {
+			t.Errorf("unexpected rate of %s: %v, expected: %v", c.target, l.rate, c.rate)
+		}
+		// the limiter is shared by the jobs of the same target
+		if last != nil && l != last {
+			t.Errorf("the limiter of %s is not shared", c.target)
+		}
+		last = l
+	}
+}
+
+func TestBandwidthLimiterReader(t *testing.T) {
+	data := make([]byte, 50*1024)
+	cases := []struct {
+		limiter *bandwidthLimiter
+		min     time.Duration
+		max     time.Duration
+	}{
+		// unlimited
+		{nil, 0, 100 * time.Millisecond},
+		// 50KB at 100KB/s, the bucket is empty at first
+		{&bandwidthLimiter{rate: 100 * 1024, last: time.Now()}, 400 * time.Millisecond, 1 * time.Second},
+	}
+
+	for _, c := range cases {
+		start := time.Now()
+		n, err := io.Copy(ioutil.Discard, c.limiter.reader(bytes.NewReader(data)))
+		elapsed := time.Since(start)
+		if err != nil || n != int64(len(data)) {
+			t.Errorf("unexpected result of reading: %d bytes, error: %v", n, err)
+		}
+		if elapsed < c.min || elapsed > c.max {
+			t.Errorf("reading %d bytes takes %v, expected between %v and %v", len(data), elapsed, c.min, c.max)
+		}
+	}
+}
+
+func TestLimitedReaderMaxRead(t *testing.T) {
+	l := &bandwidthLimiter{rate: 1024 * 1024 * 1024, last: time.Now()}
+	r := l.reader(bytes.NewReader(make([]byte, 2*maxLimitedRead)))
+	n, err := r.Read(make([]byte, 2*maxLimitedRead))
+	if err != nil || n != maxLimitedRead {
+		t.Errorf("unexpected result of reading: %d bytes, error: %v, expected: %d bytes", n, err, maxLimitedRead)
+	}
+}
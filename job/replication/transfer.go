@@ -24,10 +24,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/schema1"
-	"github.com/docker/distribution/manifest/schema2"
 	"github.com/vmware/harbor/dao"
 	"github.com/vmware/harbor/models"
 	"github.com/vmware/harbor/utils/log"
@@ -55,16 +55,34 @@ var (
 
 // BaseHandler holds informations shared by other state handlers
 type BaseHandler struct {
+	jobID      int64
+	policy     *models.RepPolicy
 	project    string // project_name
 	repository string // prject_name/repo_name
 	tags       []string
 
-	srcURL    string // url of source registry
-	srcSecret string
+	// names of the project and repository on the remote registry, which may differ from
+	// the local ones if the policy maps them to another project or prefix
+	remoteProject    string
+	remoteRepository string
 
-	dstURL string // url of target registry
-	dstUsr string // username ...
-	dstPwd string // password ...
+	srcRepository string // name of the repository on source registry
+	dstRepository string // name of the repository on destination registry
+
+	localURL    string // url of local registry
+	localSecret string
+
+	remoteURL  string // url of remote registry
+	remoteUsr  string // username ...
+	remotePwd  string // password ...
+	remoteType int    // harbor or registry
+
+	// pull images from remote registry into local registry if true,
+	// otherwise push images from local registry to remote registry
+	pull bool
+
+	srcURL string // url of source registry
+	dstURL string // url of destination registry
 
 	insecure bool // whether skip secure check when using https
 
@@ -73,31 +91,63 @@ type BaseHandler struct {
 
 	manifest distribution.Manifest // manifest of tags[0]
 	digest   string                //digest of tags[0]'s manifest
+	children []*childManifest      // manifests referenced by tags[0]'s manifest if it's a manifest list or an OCI index
 	blobs    []string              // blobs need to be transferred for tags[0]
 
-	blobsExistence map[string]bool //key: digest of blob, value: existence
+	concurrency int   // max number of blobs transferred concurrently
+	chunkSize   int64 // size of chunks in which blobs are uploaded
+
+	blobsLock      sync.Mutex               // protects blobsExistence and blobsInflight
+	blobsExistence map[string]bool          //key: digest of blob, value: existence
+	blobsInflight  map[string]chan struct{} //key: digest of blob being transferred, closed when done
+
+	progress *progressTracker
+	limiter  *bandwidthLimiter // nil if the bandwidth to the remote registry is unlimited
 
 	logger *log.Logger
 }
 
-// InitBaseHandler initializes a BaseHandler.
-func InitBaseHandler(repository, srcURL, srcSecret,
-	dstURL, dstUsr, dstPwd string, insecure bool, tags []string, logger *log.Logger) *BaseHandler {
+// InitBaseHandler initializes a BaseHandler. The direction of replication is decided by the policy.
+func InitBaseHandler(jobID int64, policy *models.RepPolicy, repository, localURL, localSecret,
+	remoteURL, remoteUsr, remotePwd string, remoteType int, bandwidthLimit int64, insecure bool, tags []string, concurrency int, chunkSize int64, logger *log.Logger) *BaseHandler {
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
 
 	base := &BaseHandler{
+		jobID:          jobID,
+		policy:         policy,
 		repository:     repository,
 		tags:           tags,
-		srcURL:         srcURL,
-		srcSecret:      srcSecret,
-		dstURL:         dstURL,
-		dstUsr:         dstUsr,
-		dstPwd:         dstPwd,
+		localURL:       localURL,
+		localSecret:    localSecret,
+		remoteURL:      remoteURL,
+		remoteUsr:      remoteUsr,
+		remotePwd:      remotePwd,
+		remoteType:     remoteType,
+		pull:           policy.Direction == models.RepDirectionPull,
 		insecure:       insecure,
+		concurrency:    concurrency,
+		chunkSize:      chunkSize,
 		blobsExistence: make(map[string]bool, 10),
+		blobsInflight:  make(map[string]chan struct{}),
+		progress:       newProgressTracker(jobID),
+		limiter:        getBandwidthLimiter(remoteURL, bandwidthLimit),
 		logger:         logger,
 	}
 
 	base.project = getProjectName(base.repository)
+	base.remoteProject = policy.RemoteProject(base.project)
+	base.remoteRepository = policy.RemoteRepository(base.repository)
+
+	if base.pull {
+		base.srcURL, base.dstURL = remoteURL, localURL
+		base.srcRepository, base.dstRepository = base.remoteRepository, base.repository
+	} else {
+		base.srcURL, base.dstURL = localURL, remoteURL
+		base.srcRepository, base.dstRepository = base.repository, base.remoteRepository
+	}
 
 	return base
 }
@@ -121,32 +171,41 @@ type Initializer struct {
 
 // Enter ...
 func (i *Initializer) Enter() (string, error) {
-	i.logger.Infof("initializing: repository: %s, tags: %v, source URL: %s, destination URL: %s, insecure: %v, destination user: %s",
-		i.repository, i.tags, i.srcURL, i.dstURL, i.insecure, i.dstUsr)
+	i.logger.Infof("initializing: repository: %s, tags: %v, source URL: %s, destination URL: %s, insecure: %v, remote user: %s",
+		i.repository, i.tags, i.srcURL, i.dstURL, i.insecure, i.remoteUsr)
 
 	state, err := i.enter()
-	if err != nil && retry(err) {
-		i.logger.Info("waiting for retrying...")
-		return models.JobRetrying, nil
+	if err != nil {
+		if retry(err) {
+			i.logger.Info("waiting for retrying...")
+			return models.JobRetrying, nil
+		}
+		return state, err
 	}
 
-	return state, err
+	return state, nil
 }
 
 func (i *Initializer) enter() (string, error) {
-	c := &http.Cookie{Name: models.UISecretCookie, Value: i.srcSecret}
-	srcCred := auth.NewCookieCredential(c)
+	c := &http.Cookie{Name: models.UISecretCookie, Value: i.localSecret}
+	localCred := auth.NewCookieCredential(c)
+	remoteCred := auth.NewBasicAuthCredential(i.remoteUsr, i.remotePwd)
+
+	srcCred, dstCred := localCred, remoteCred
+	if i.pull {
+		srcCred, dstCred = remoteCred, localCred
+	}
+
 	srcClient, err := newRepositoryClient(i.srcURL, i.insecure, srcCred,
-		i.repository, "repository", i.repository, "pull", "push", "*")
+		i.srcRepository, "repository", i.srcRepository, "pull", "push", "*")
 	if err != nil {
 		i.logger.Errorf("an error occurred while creating source repository client: %v", err)
 		return "", err
 	}
 	i.srcClient = srcClient
 
-	dstCred := auth.NewBasicAuthCredential(i.dstUsr, i.dstPwd)
 	dstClient, err := newRepositoryClient(i.dstURL, i.insecure, dstCred,
-		i.repository, "repository", i.repository, "pull", "push", "*")
+		i.dstRepository, "repository", i.dstRepository, "pull", "push", "*")
 	if err != nil {
 		i.logger.Errorf("an error occurred while creating destination repository client: %v", err)
 		return "", err
@@ -162,8 +221,15 @@ func (i *Initializer) enter() (string, error) {
 		i.tags = tags
 	}
 
-	i.logger.Infof("initialization completed: project: %s, repository: %s, tags: %v, source URL: %s, destination URL: %s, insecure: %v, destination user: %s",
-		i.project, i.repository, i.tags, i.srcURL, i.dstURL, i.insecure, i.dstUsr)
+	// only the tags which match the filters of policy are replicated
+	i.tags = i.policy.FilterTags(i.tags)
+
+	i.progress.update(true, func(p *models.RepJobProgress) {
+		p.TagsTotal = len(i.tags)
+	})
+
+	i.logger.Infof("initialization completed: project: %s, repository: %s, remote repository: %s, tags: %v, source URL: %s, destination URL: %s, insecure: %v, remote user: %s",
+		i.project, i.repository, i.remoteRepository, i.tags, i.srcURL, i.dstURL, i.insecure, i.remoteUsr)
 
 	return StateCheck, nil
 }
@@ -175,6 +241,7 @@ type Checker struct {
 
 // Enter check existence of project, if it does not exist, create it,
 // if it exists, check whether the user has write privilege to it.
+// In pull mode the project is checked in local DB.
 func (c *Checker) Enter() (string, error) {
 	state, err := c.enter()
 	if err != nil && retry(err) {
@@ -186,6 +253,16 @@ func (c *Checker) Enter() (string, error) {
 }
 
 func (c *Checker) enter() (string, error) {
+	if c.pull {
+		return c.enterPull()
+	}
+
+	// the regular registry has no concept of project
+	if c.remoteType == models.RepTargetTypeRegistry {
+		c.logger.Infof("%s is a regular registry, skip creating project %s", c.dstURL, c.remoteProject)
+		return StatePullManifest, nil
+	}
+
 	project, err := dao.GetProjectByName(c.project)
 	if err != nil {
 		c.logger.Errorf("an error occurred while getting project %s in DB: %v", c.project, err)
@@ -194,7 +271,7 @@ func (c *Checker) enter() (string, error) {
 
 	err = c.createProject(project.Public == 1)
 	if err == nil {
-		c.logger.Infof("project %s is created on %s with user %s", c.project, c.dstURL, c.dstUsr)
+		c.logger.Infof("project %s is created on %s with user %s", c.remoteProject, c.dstURL, c.remoteUsr)
 		return StatePullManifest, nil
 	}
 
@@ -202,21 +279,39 @@ func (c *Checker) enter() (string, error) {
 	// is creating project, so when the response code is 409, continue
 	// to do next step
 	if err == ErrConflict {
-		c.logger.Warningf("the status code is 409 when creating project %s on %s with user %s, try to do next step", c.project, c.dstURL, c.dstUsr)
+		c.logger.Warningf("the status code is 409 when creating project %s on %s with user %s, try to do next step", c.remoteProject, c.dstURL, c.remoteUsr)
 		return StatePullManifest, nil
 	}
 
-	c.logger.Errorf("an error occurred while creating project %s on %s with user %s : %v", c.project, c.dstURL, c.dstUsr, err)
+	c.logger.Errorf("an error occurred while creating project %s on %s with user %s : %v", c.remoteProject, c.dstURL, c.remoteUsr, err)
 
 	return "", err
 }
 
+// enterPull checks the local project which the images are pulled into, it's not created by the job:
+// a project needs an owner, which the policy doesn't record, and the project of a pull-mode policy
+// exists when the policy is created, so it can only be missing if it was deleted afterwards.
+func (c *Checker) enterPull() (string, error) {
+	project, err := dao.GetProjectByName(c.project)
+	if err != nil {
+		c.logger.Errorf("an error occurred while getting project %s in DB: %v", c.project, err)
+		return "", err
+	}
+	if project == nil {
+		err = fmt.Errorf("project %s does not exist in local, it may have been deleted after policy %d was created", c.project, c.policy.ID)
+		c.logger.Errorf("%v", err)
+		return "", err
+	}
+
+	return StatePullManifest, nil
+}
+
 func (c *Checker) createProject(isPublic bool) error {
 	project := struct {
 		ProjectName string `json:"project_name"`
 		Public      bool   `json:"public"`
 	}{
-		ProjectName: c.project,
+		ProjectName: c.remoteProject,
 		Public:      isPublic,
 	}
 
@@ -231,7 +326,7 @@ func (c *Checker) createProject(isPublic bool) error {
 		return err
 	}
 
-	req.SetBasicAuth(c.dstUsr, c.dstPwd)
+	req.SetBasicAuth(c.remoteUsr, c.remotePwd)
 
 	client := &http.Client{
 		Transport: &http.Transport{
@@ -264,11 +359,47 @@ func (c *Checker) createProject(isPublic bool) error {
 	}
 
 	return fmt.Errorf("failed to create project %s on %s with user %s: %d %s",
-		c.project, c.dstURL, c.dstUsr, resp.StatusCode, string(message))
+		c.remoteProject, c.dstURL, c.remoteUsr, resp.StatusCode, string(message))
+}
+
+// remoteProjectExists checks the existence of the project on the remote Harbor
+func (b *BaseHandler) remoteProjectExists() (bool, error) {
+	url := strings.TrimRight(b.remoteURL, "/") + "/api/projects/?project_name=" + b.remoteProject
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	req.SetBasicAuth(b.remoteUsr, b.remotePwd)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: b.insecure,
+			},
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+
+	return false, fmt.Errorf("failed to check the existence of project %s on %s with user %s: %d",
+		b.remoteProject, b.remoteURL, b.remoteUsr, resp.StatusCode)
 }
 
 // ManifestPuller pulls the manifest of a tag. And if no tag needs to be pulled,
-// the next state that state machine should enter is "finished".
+// the next state that state machine should enter is "finished". If the tag is skipped
+// because of the conflict mode of the policy, it enters "pull_manifest" again for the next tag.
 type ManifestPuller struct {
 	*BaseHandler
 }
@@ -288,60 +419,58 @@ func (m *ManifestPuller) Enter() (string, error) {
 func (m *ManifestPuller) enter() (string, error) {
 	if len(m.tags) == 0 {
 		m.logger.Infof("no tag needs to be replicated, next state is \"finished\"")
+		m.progress.update(true, func(p *models.RepJobProgress) {
+			p.CurrentTag = ""
+			p.TotalsKnown = true
+		})
 		return models.JobFinished, nil
 	}
 
 	name := m.repository
 	tag := m.tags[0]
 
-	acceptMediaTypes := []string{schema1.MediaTypeManifest, schema2.MediaTypeManifest}
-	digest, mediaType, payload, err := m.srcClient.PullManifest(tag, acceptMediaTypes)
+	digest, manifest, err := m.pullManifest(tag)
 	if err != nil {
-		m.logger.Errorf("an error occurred while pulling manifest of %s:%s from %s: %v", name, tag, m.srcURL, err)
 		return "", err
 	}
 	m.digest = digest
-	m.logger.Infof("manifest of %s:%s pulled successfully from %s: %s", name, tag, m.srcURL, digest)
+	m.manifest = manifest
 
-	if strings.Contains(mediaType, "application/json") {
-		mediaType = schema1.MediaTypeManifest
+	// check before transferring any blob
+	skip, err := m.checkConflict(tag)
+	if err != nil {
+		return "", err
+	}
+	if skip {
+		m.nextTag()
+		return StatePullManifest, nil
 	}
 
-	manifest, _, err := registry.UnMarshal(mediaType, payload)
+	children, err := m.pullChildren(tag, manifest)
 	if err != nil {
-		m.logger.Errorf("an error occurred while parsing manifest of %s:%s from %s: %v", name, tag, m.srcURL, err)
 		return "", err
 	}
-
-	m.manifest = manifest
+	m.children = children
 
 	// all blobs(layers and config)
 	var blobs []string
-
-	for _, discriptor := range manifest.References() {
-		blobs = append(blobs, discriptor.Digest.String())
-	}
-
-	// config is also need to be transferred if the schema of manifest is v2
-	manifest2, ok := manifest.(*schema2.DeserializedManifest)
-	if ok {
-		blobs = append(blobs, manifest2.Target().Digest.String())
+	sizes := make(map[string]int64)
+	for _, descriptor := range blobsOf(manifest, children) {
+		blobs = append(blobs, descriptor.Digest.String())
+		sizes[descriptor.Digest.String()] = descriptor.Size
 	}
 
 	m.logger.Infof("all blobs of %s:%s from %s: %v", name, tag, m.srcURL, blobs)
 
-	for _, blob := range blobs {
-		exist, ok := m.blobsExistence[blob]
-		if !ok {
-			exist, err = m.dstClient.BlobExist(blob)
-			if err != nil {
-				m.logger.Errorf("an error occurred while checking existence of blob %s of %s:%s on %s: %v", blob, name, tag, m.dstURL, err)
-				return "", err
-			}
-			m.blobsExistence[blob] = exist
-		}
+	// the layers of schema1 manifest may be duplicate
+	blobs = unique(blobs)
 
-		if !exist {
+	if err = m.checkBlobsExistence(tag, blobs); err != nil {
+		return "", err
+	}
+
+	for _, blob := range blobs {
+		if !m.blobsExistence[blob] {
 			m.blobs = append(m.blobs, blob)
 		} else {
 			m.logger.Infof("blob %s of %s:%s already exists in %s", blob, name, tag, m.dstURL)
@@ -349,9 +478,127 @@ func (m *ManifestPuller) enter() (string, error) {
 	}
 	m.logger.Infof("blobs of %s:%s need to be transferred to %s: %v", name, tag, m.dstURL, m.blobs)
 
+	// the totals grow tag by tag, the blobs transferred for the previous tags exist by now,
+	// so they are not counted twice. They are complete once the manifest of the last tag is pulled.
+	var bytes int64
+	for _, blob := range m.blobs {
+		bytes += sizes[blob]
+	}
+	m.progress.update(true, func(p *models.RepJobProgress) {
+		p.CurrentTag = tag
+		p.BlobsTotal += len(m.blobs)
+		p.BytesTotal += bytes
+		p.TotalsKnown = len(m.tags) == 1
+	})
+
 	return StateTransferBlob, nil
 }
 
+// pullManifest pulls the manifest of the tag from the source registry and parses it
+func (b *BaseHandler) pullManifest(tag string) (string, distribution.Manifest, error) {
+	name := b.repository
+
+	digest, mediaType, payload, err := b.srcClient.PullManifest(tag, registry.ManifestMediaTypes)
+	if err != nil {
+		b.logger.Errorf("an error occurred while pulling manifest of %s:%s from %s: %v", name, tag, b.srcURL, err)
+		return "", nil, err
+	}
+	b.logger.Infof("manifest of %s:%s pulled successfully from %s: %s", name, tag, b.srcURL, digest)
+
+	if strings.Contains(mediaType, "application/json") {
+		mediaType = schema1.MediaTypeManifest
+	}
+
+	manifest, _, err := registry.UnMarshal(mediaType, payload)
+	if err != nil {
+		b.logger.Errorf("an error occurred while parsing manifest of %s:%s from %s: %v", name, tag, b.srcURL, err)
+		return "", nil, err
+	}
+
+	return digest, manifest, nil
+}
+
+// childManifest is a manifest referenced by a manifest list or an OCI index
+type childManifest struct {
+	digest   string
+	manifest distribution.Manifest
+}
+
+// pullChildren pulls the manifests referenced by the manifest if it's a manifest list or an OCI
+// index, it returns nil for the other manifests.
+func (b *BaseHandler) pullChildren(tag string, manifest distribution.Manifest) ([]*childManifest, error) {
+	if !registry.IsIndex(manifest) {
+		return nil, nil
+	}
+
+	var children []*childManifest
+	for _, descriptor := range manifest.References() {
+		digest := descriptor.Digest.String()
+		_, child, err := b.pullManifest(digest)
+		if err != nil {
+			return nil, err
+		}
+		if registry.IsIndex(child) {
+			err = fmt.Errorf("manifest %s referenced by %s:%s is an index, which is not supported", digest, b.repository, tag)
+			b.logger.Errorf("%v", err)
+			return nil, err
+		}
+		children = append(children, &childManifest{
+			digest:   digest,
+			manifest: child,
+		})
+	}
+	b.logger.Infof("%d manifests referenced by %s:%s pulled from %s", len(children), b.repository, tag, b.srcURL)
+
+	return children, nil
+}
+
+// blobsOf returns the blobs(layers and config) referenced by the manifest, the blobs of a
+// manifest list or an OCI index are the ones referenced by its children.
+func blobsOf(manifest distribution.Manifest, children []*childManifest) []distribution.Descriptor {
+	if registry.IsIndex(manifest) {
+		var descriptors []distribution.Descriptor
+		for _, child := range children {
+			descriptors = append(descriptors, blobsOf(child.manifest, nil)...)
+		}
+		return descriptors
+	}
+
+	descriptors := manifest.References()
+
+	// config is also need to be transferred if the schema of manifest is v2 or OCI
+	if config, ok := registry.ConfigOf(manifest); ok {
+		descriptors = append(descriptors, config)
+	}
+
+	return descriptors
+}
+
+// checkBlobsExistence checks the existence of the blobs on the destination registry in
+// parallel and records the result in blobsExistence, the blobs checked before are skipped.
+func (b *BaseHandler) checkBlobsExistence(tag string, blobs []string) error {
+	return parallel(b.concurrency, blobs, func(blob string) error {
+		b.blobsLock.Lock()
+		_, ok := b.blobsExistence[blob]
+		b.blobsLock.Unlock()
+		if ok {
+			return nil
+		}
+		exist, err := b.dstClient.BlobExist(blob)
+		if err != nil {
+			b.logger.Errorf("an error occurred while checking existence of blob %s of %s:%s on %s: %v", blob, b.repository, tag, b.dstURL, err)
+			return err
+		}
+		b.blobsLock.Lock()
+		b.blobsExistence[blob] = exist
+		b.blobsLock.Unlock()
+		if exist {
+			recordBlobHolder(b.dstURL, b.dstRepository, blob)
+		}
+		return nil
+	})
+}
+
 // BlobTransfer transfers blobs of a tag
 type BlobTransfer struct {
 	*BaseHandler
@@ -370,26 +617,133 @@ func (b *BlobTransfer) Enter() (string, error) {
 }
 
 func (b *BlobTransfer) enter() (string, error) {
-	name := b.repository
-	tag := b.tags[0]
-	for _, blob := range b.blobs {
-		b.logger.Infof("transferring blob %s of %s:%s to %s ...", blob, name, tag, b.dstURL)
-		size, data, err := b.srcClient.PullBlob(blob)
-		if err != nil {
-			b.logger.Errorf("an error occurred while pulling blob %s of %s:%s from %s: %v", blob, name, tag, b.srcURL, err)
-			return "", err
+	if err := parallel(b.concurrency, b.blobs, b.transferBlob); err != nil {
+		return "", err
+	}
+
+	return StatePushManifest, nil
+}
+
+// transferBlob transfers the blob if it is neither existing on the destination registry nor
+// being transferred by another goroutine, otherwise it waits for the other goroutine to finish,
+// so that a blob is never uploaded twice at the same time.
+func (b *BlobTransfer) transferBlob(blob string) error {
+	for {
+		b.blobsLock.Lock()
+		if b.blobsExistence[blob] {
+			b.blobsLock.Unlock()
+			return nil
 		}
-		if data != nil {
-			defer data.Close()
+		done, ok := b.blobsInflight[blob]
+		if !ok {
+			b.blobsInflight[blob] = make(chan struct{})
+			b.blobsLock.Unlock()
+			break
 		}
-		if err = b.dstClient.PushBlob(blob, size, data); err != nil {
-			b.logger.Errorf("an error occurred while pushing blob %s of %s:%s to %s : %v", blob, name, tag, b.dstURL, err)
-			return "", err
+		b.blobsLock.Unlock()
+		// if the other transfer fails, try again
+		<-done
+	}
+
+	err := b.mountOrTransferBlob(blob)
+
+	b.blobsLock.Lock()
+	if err == nil {
+		b.blobsExistence[blob] = true
+	}
+	close(b.blobsInflight[blob])
+	delete(b.blobsInflight, blob)
+	b.blobsLock.Unlock()
+
+	if err == nil {
+		b.progress.update(false, func(p *models.RepJobProgress) {
+			p.BlobsDone++
+		})
+	}
+
+	return err
+}
+
+// mountOrTransferBlob tries to mount the blob from another repository which is known to hold it
+// on the destination registry, and falls back to transferring the blob if it can not be mounted.
+func (b *BlobTransfer) mountOrTransferBlob(blob string) error {
+	if from := blobHolder(b.dstURL, b.dstRepository, blob); len(from) != 0 {
+		mounted, err := b.dstClient.MountBlob(blob, from)
+		if err != nil {
+			b.logger.Warningf("an error occurred while mounting blob %s from %s on %s, will transfer it: %v", blob, from, b.dstURL, err)
+		} else if mounted {
+			b.logger.Infof("blob %s is mounted from %s on %s", blob, from, b.dstURL)
+			recordBlobHolder(b.dstURL, b.dstRepository, blob)
+			return nil
 		}
-		b.logger.Infof("blob %s of %s:%s transferred to %s completed", blob, name, tag, b.dstURL)
 	}
 
-	return StatePushManifest, nil
+	if err := b.pullAndPushBlob(blob); err != nil {
+		return err
+	}
+	recordBlobHolder(b.dstURL, b.dstRepository, blob)
+	return nil
+}
+
+// pullAndPushBlob uploads the blob in chunks, if the upload is interrupted, the session is kept
+// and the upload will be resumed from where it stopped when the job is retried.
+func (b *BlobTransfer) pullAndPushBlob(blob string) error {
+	name := b.repository
+	tag := b.tags[0]
+	b.logger.Infof("transferring blob %s of %s:%s to %s ...", blob, name, tag, b.dstURL)
+
+	upload, err := b.resumeUpload(blob)
+	if err != nil {
+		return err
+	}
+
+	size, data, err := b.srcClient.PullBlobFrom(blob, upload.Offset)
+	if err != nil {
+		b.logger.Errorf("an error occurred while pulling blob %s of %s:%s from %s: %v", blob, name, tag, b.srcURL, err)
+		b.saveUpload(blob, upload)
+		return err
+	}
+	if data != nil {
+		defer data.Close()
+	}
+	if err = b.dstClient.PushBlobChunked(upload, blob, size, b.chunkSize, b.progress.reader(b.limiter.reader(data))); err != nil {
+		b.logger.Errorf("an error occurred while pushing blob %s of %s:%s to %s : %v", blob, name, tag, b.dstURL, err)
+		b.saveUpload(blob, upload)
+		return err
+	}
+	b.logger.Infof("blob %s of %s:%s transferred to %s completed", blob, name, tag, b.dstURL)
+	return nil
+}
+
+// resumeUpload continues the interrupted upload session of the blob if there is one,
+// otherwise a new session is initiated.
+func (b *BlobTransfer) resumeUpload(blob string) (*registry.BlobUpload, error) {
+	interrupted, err := takeUpload(b.jobID, blob)
+	if err != nil {
+		b.logger.Warningf("failed to get the interrupted upload of blob %s, will start a new upload: %v", blob, err)
+	}
+	if interrupted != nil {
+		upload, err := b.dstClient.ResumeBlobUpload(interrupted.Location)
+		if err == nil {
+			b.logger.Infof("resuming the upload of blob %s to %s from offset %d", blob, b.dstURL, upload.Offset)
+			return upload, nil
+		}
+		b.logger.Warningf("failed to resume the upload of blob %s to %s, will start a new upload: %v", blob, b.dstURL, err)
+	}
+
+	upload, err := b.dstClient.InitiateBlobUpload()
+	if err != nil {
+		b.logger.Errorf("an error occurred while initiating the upload of blob %s to %s: %v", blob, b.dstURL, err)
+		return nil, err
+	}
+	return upload, nil
+}
+
+// saveUpload keeps the interrupted upload session so that it can be resumed when the job is retried
+func (b *BlobTransfer) saveUpload(blob string, upload *registry.BlobUpload) {
+	if err := saveUpload(b.jobID, blob, upload); err != nil {
+		b.logger.Warningf("failed to save the interrupted upload of blob %s, it will be started over: %v", blob, err)
+	}
 }
 
 // ManifestPusher pushs the manifest to destination registry
@@ -428,14 +782,27 @@ func (m *ManifestPusher) enter() (string, error) {
 		if manifestExist && digest == m.digest {
 			m.logger.Infof("manifest of %s:%s exists on destination registry %s, skip manifest pushing", name, tag, m.dstURL)
 
-			m.tags = m.tags[1:]
-			m.manifest = nil
-			m.digest = ""
-			m.blobs = nil
+			m.nextTag()
 
 			return StatePullManifest, nil
 		}
 
+		// the tag may have been pushed to the destination registry since it was checked
+		skip, err := m.checkConflict(tag)
+		if err != nil {
+			return "", err
+		}
+		if skip {
+			m.nextTag()
+
+			return StatePullManifest, nil
+		}
+
+		// the manifests referenced by the index must exist before the index is pushed
+		if err = m.pushChildren(tag); err != nil {
+			return "", err
+		}
+
 		mediaType, data, err := m.manifest.Payload()
 		if err != nil {
 			m.logger.Errorf("an error occurred while getting payload of manifest for %s:%s : %v", name, tag, err)
@@ -449,20 +816,107 @@ func (m *ManifestPusher) enter() (string, error) {
 		m.logger.Infof("manifest of %s:%s has been pushed to %s", name, tag, m.dstURL)
 	}
 
-	m.tags = m.tags[1:]
-	m.manifest = nil
-	m.digest = ""
-	m.blobs = nil
+	m.nextTag()
 
 	return StatePullManifest, nil
 }
 
+// pushChildren pushes the manifests referenced by the manifest list or OCI index of the tag
+// by digest, the ones already existing on the destination registry are skipped.
+func (m *ManifestPusher) pushChildren(tag string) error {
+	for _, child := range m.children {
+		_, exist, err := m.dstClient.ManifestExist(child.digest)
+		if err != nil {
+			m.logger.Errorf("an error occurred while checking the existence of manifest %s on %s: %v", child.digest, m.dstURL, err)
+			return err
+		}
+		if exist {
+			continue
+		}
+
+		mediaType, data, err := child.manifest.Payload()
+		if err != nil {
+			m.logger.Errorf("an error occurred while getting payload of manifest %s referenced by %s:%s : %v", child.digest, m.repository, tag, err)
+			return err
+		}
+		if _, err = m.dstClient.PushManifest(child.digest, mediaType, data); err != nil {
+			m.logger.Errorf("an error occurred while pushing manifest %s referenced by %s:%s to %s : %v", child.digest, m.repository, tag, m.dstURL, err)
+			return err
+		}
+		m.logger.Infof("manifest %s referenced by %s:%s has been pushed to %s", child.digest, m.repository, tag, m.dstURL)
+	}
+	return nil
+}
+
+// nextTag moves on to the next tag and records the remaining tags as the checkpoint of the
+// job, so that the job is resumed from the next tag rather than the first one if it is interrupted.
+func (b *BaseHandler) nextTag() {
+	b.progress.update(true, func(p *models.RepJobProgress) {
+		p.TagsDone++
+	})
+	b.tags = b.tags[1:]
+	b.manifest = nil
+	b.digest = ""
+	b.children = nil
+	b.blobs = nil
+
+	if err := dao.UpdateRepJobCheckpoint(b.jobID, b.tags); err != nil {
+		b.logger.Warningf("an error occurred while recording the checkpoint of job %d: %v", b.jobID, err)
+	}
+}
+
+// checkConflict handles the tag according to the conflict mode of the policy if it exists on the
+// destination registry with a digest different from the one pulled from the source. It returns
+// true if the tag should be skipped, and an error if the job should fail for the conflict.
+func (b *BaseHandler) checkConflict(tag string) (bool, error) {
+	mode := b.policy.ConflictMode
+	if mode != models.ConflictSkip && mode != models.ConflictFail {
+		return false, nil
+	}
+
+	digest, exist, err := b.dstClient.ManifestExist(tag)
+	if err != nil {
+		b.logger.Errorf("an error occurred while checking the existence of manifest of %s:%s on %s: %v", b.repository, tag, b.dstURL, err)
+		return false, err
+	}
+	if !exist || digest == b.digest {
+		return false, nil
+	}
+
+	result := &models.RepJobResult{
+		JobID:      b.jobID,
+		Repository: b.repository,
+		Tag:        tag,
+		Result:     models.TagResultSkipped,
+		SrcDigest:  b.digest,
+		DstDigest:  digest,
+	}
+	if mode == models.ConflictFail {
+		result.Result = models.TagResultConflict
+	}
+	if err = dao.AddRepJobResult(result); err != nil {
+		b.logger.Errorf("an error occurred while recording the result of %s:%s: %v", b.repository, tag, err)
+		return false, err
+	}
+
+	if mode == models.ConflictSkip {
+		b.logger.Warningf("%s:%s already exists on %s with digest %s, which differs from %s, skip it",
+			b.repository, tag, b.dstURL, digest, b.digest)
+		return true, nil
+	}
+
+	b.logger.Errorf("%s:%s already exists on %s with digest %s, which differs from %s",
+		b.repository, tag, b.dstURL, digest, b.digest)
+	return false, fmt.Errorf("%s:%s already exists on %s with a different digest", b.repository, tag, b.dstURL)
+}
+
 func newRepositoryClient(endpoint string, insecure bool, credential auth.Credential, repository, scopeType, scopeName string,
 	scopeActions ...string) (*registry.Repository, error) {
 
 	authorizer := auth.NewStandardTokenAuthorizer(credential, insecure, scopeType, scopeName, scopeActions...)
 
-	store, err := auth.NewAuthorizerStore(endpoint, insecure, authorizer)
+	// the regular registry may use basic auth rather than token auth
+	store, err := auth.NewAuthorizerStore(endpoint, insecure, authorizer, auth.NewBasicAuthorizer(credential))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,63 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package replication
+
+import (
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/registry"
+)
+
+// The blob upload sessions which are interrupted are stored in DB per job and blob, so that they
+// can be resumed when the job is retried, even if it's claimed by another node or after the
+// job service restarts. They are cleared when the job ends. A blob is transferred by only one
+// goroutine of a job at a time, so the sessions need no lock.
+
+// takeUpload removes the interrupted session of the blob from the job and returns it, nil if not found.
+func takeUpload(jobID int64, blob string) (*registry.BlobUpload, error) {
+	// there is no job record for dry runs
+	if jobID == 0 {
+		return nil, nil
+	}
+
+	upload, err := dao.GetRepJobUpload(jobID, blob)
+	if err != nil || upload == nil {
+		return nil, err
+	}
+	if err = dao.DeleteRepJobUpload(jobID, blob); err != nil {
+		return nil, err
+	}
+	return &registry.BlobUpload{
+		Location: upload.Location,
+		UUID:     upload.UUID,
+		Offset:   upload.Offset,
+	}, nil
+}
+
+// saveUpload stores the interrupted session of the blob on the job
+func saveUpload(jobID int64, blob string, upload *registry.BlobUpload) error {
+	if jobID == 0 {
+		return nil
+	}
+
+	return dao.SaveRepJobUpload(&models.RepBlobUpload{
+		JobID:    jobID,
+		Digest:   blob,
+		Location: upload.Location,
+		UUID:     upload.UUID,
+		Offset:   upload.Offset,
+	})
+}
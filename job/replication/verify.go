@@ -0,0 +1,407 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package replication
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/log"
+	"github.com/vmware/harbor/utils/registry/auth"
+)
+
+const (
+	// StateVerify ...
+	StateVerify = "verify"
+)
+
+// Verifier compares the manifest digests of all the tags of a policy on the source
+// and destination registry, records the differences in DB and, if repair is true,
+// enqueues transfer jobs for the missing and stale tags. If prune is true, it enqueues
+// delete jobs for the tags which only exist on the destination.
+type Verifier struct {
+	jobID  int64
+	policy *models.RepPolicy
+
+	localURL    string
+	localSecret string
+	remoteURL   string
+	remoteUsr   string
+	remotePwd   string
+
+	insecure    bool
+	concurrency int
+	repair      bool
+	prune       bool
+
+	// list the repositories of the project of the policy on local and remote registry,
+	// the names of the remote repositories are mapped to the local ones
+	localRepos  func() ([]string, error)
+	remoteRepos func() ([]string, error)
+	// enqueues a repair job
+	addJob func(models.RepJob) (int64, error)
+
+	logger *log.Logger
+}
+
+// NewVerifier returns a Verifier
+func NewVerifier(jobID int64, policy *models.RepPolicy, localURL, localSecret, remoteURL, remoteUsr, remotePwd string,
+	insecure bool, concurrency int, repair, prune bool, localRepos, remoteRepos func() ([]string, error),
+	addJob func(models.RepJob) (int64, error), logger *log.Logger) *Verifier {
+	return &Verifier{
+		jobID:       jobID,
+		policy:      policy,
+		localURL:    localURL,
+		localSecret: localSecret,
+		remoteURL:   remoteURL,
+		remoteUsr:   remoteUsr,
+		remotePwd:   remotePwd,
+		insecure:    insecure,
+		concurrency: concurrency,
+		repair:      repair,
+		prune:       prune,
+		localRepos:  localRepos,
+		remoteRepos: remoteRepos,
+		addJob:      addJob,
+		logger:      logger,
+	}
+}
+
+// Exit ...
+func (v *Verifier) Exit() error {
+	return nil
+}
+
+// Enter verifies the policy
+func (v *Verifier) Enter() (string, error) {
+	state, err := v.enter()
+	if err != nil && retry(err) {
+		v.logger.Info("waiting for retrying...")
+		return models.JobRetrying, nil
+	}
+
+	return state, err
+}
+
+// side is the source or destination registry of the policy
+type side struct {
+	url        string
+	credential auth.Credential
+	listRepos  func() ([]string, error)
+	// returns the name on the registry of the local repository
+	repository func(string) string
+}
+
+func (v *Verifier) enter() (string, error) {
+	drifts, dstTotal, err := v.diff()
+	if err != nil {
+		return "", err
+	}
+
+	// the job may be retried, remove the differences recorded by the previous attempt
+	if err = dao.DeleteRepDriftsByJob(v.jobID); err != nil {
+		v.logger.Errorf("an error occurred while removing the differences of job %d: %v", v.jobID, err)
+		return "", err
+	}
+	if err = dao.AddRepDrifts(drifts); err != nil {
+		v.logger.Errorf("an error occurred while recording the differences of policy %d: %v", v.policy.ID, err)
+		return "", err
+	}
+	v.logger.Infof("verification of policy %d completed, %d differences found", v.policy.ID, len(drifts))
+
+	if v.repair {
+		if err = v.enqueueRepairJobs(drifts); err != nil {
+			return "", err
+		}
+	}
+
+	if v.prune {
+		if err = v.enqueuePruneJobs(drifts, dstTotal); err != nil {
+			return "", err
+		}
+	}
+
+	return models.JobContinue, nil
+}
+
+// diff compares the source and destination of the policy, it returns the differences and
+// the number of the tags on the destination.
+func (v *Verifier) diff() ([]*models.RepDrift, int, error) {
+	local := &side{
+		url:        v.localURL,
+		credential: auth.NewCookieCredential(&http.Cookie{Name: models.UISecretCookie, Value: v.localSecret}),
+		listRepos:  v.localRepos,
+		repository: func(repo string) string { return repo },
+	}
+	remote := &side{
+		url:        v.remoteURL,
+		credential: auth.NewBasicAuthCredential(v.remoteUsr, v.remotePwd),
+		listRepos:  v.remoteRepos,
+		repository: v.policy.RemoteRepository,
+	}
+
+	src, dst := local, remote
+	if v.policy.Direction == models.RepDirectionPull {
+		src, dst = remote, local
+	}
+	v.logger.Infof("verifying policy %d: source URL: %s, destination URL: %s", v.policy.ID, src.url, dst.url)
+
+	srcRepos, err := v.listRepos(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	dstRepos, err := v.listRepos(dst)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var repos []string
+	for repo := range srcRepos {
+		repos = append(repos, repo)
+	}
+	for repo := range dstRepos {
+		if !srcRepos[repo] {
+			repos = append(repos, repo)
+		}
+	}
+
+	var (
+		lock   sync.Mutex
+		drifts []*models.RepDrift
+		// the number of the tags on the destination
+		dstTotal int
+	)
+	err = parallel(v.concurrency, repos, func(repo string) error {
+		var srcTags, dstTags map[string]string
+		var err error
+		if srcRepos[repo] {
+			if srcTags, err = v.listDigests(src, repo); err != nil {
+				return err
+			}
+		}
+		if dstRepos[repo] {
+			if dstTags, err = v.listDigests(dst, repo); err != nil {
+				return err
+			}
+		}
+		d := v.compare(repo, srcTags, dstTags)
+		lock.Lock()
+		drifts = append(drifts, d...)
+		dstTotal += len(dstTags)
+		lock.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return drifts, dstTotal, nil
+}
+
+// listRepos returns the repositories on the registry which match the filters of the policy
+func (v *Verifier) listRepos(s *side) (map[string]bool, error) {
+	repoList, err := s.listRepos()
+	if err != nil {
+		v.logger.Errorf("an error occurred while listing repositories on %s: %v", s.url, err)
+		return nil, err
+	}
+
+	repos := make(map[string]bool, len(repoList))
+	for _, repo := range repoList {
+		if v.policy.MatchRepository(repo) {
+			repos[repo] = true
+		}
+	}
+	return repos, nil
+}
+
+// listDigests returns the manifest digests of the tags of the repository which match the filters of the policy
+func (v *Verifier) listDigests(s *side, repository string) (map[string]string, error) {
+	repository = s.repository(repository)
+	client, err := newRepositoryClient(s.url, v.insecure, s.credential,
+		repository, "repository", repository, "pull")
+	if err != nil {
+		v.logger.Errorf("an error occurred while creating repository client for %s on %s: %v", repository, s.url, err)
+		return nil, err
+	}
+
+	tags, err := client.ListTag()
+	if err != nil {
+		v.logger.Errorf("an error occurred while listing tags of %s on %s: %v", repository, s.url, err)
+		return nil, err
+	}
+
+	digests := make(map[string]string, len(tags))
+	for _, tag := range v.policy.FilterTags(tags) {
+		digest, exist, err := client.ManifestExist(tag)
+		if err != nil {
+			v.logger.Errorf("an error occurred while checking the existence of manifest of %s:%s on %s: %v", repository, tag, s.url, err)
+			return nil, err
+		}
+		// the tag may be deleted after listing
+		if exist {
+			digests[tag] = digest
+		}
+	}
+	return digests, nil
+}
+
+func (v *Verifier) compare(repository string, srcTags, dstTags map[string]string) []*models.RepDrift {
+	var drifts []*models.RepDrift
+	add := func(tag, kind string) {
+		drifts = append(drifts, &models.RepDrift{
+			JobID:      v.jobID,
+			PolicyID:   v.policy.ID,
+			Repository: repository,
+			Tag:        tag,
+			Kind:       kind,
+			SrcDigest:  srcTags[tag],
+			DstDigest:  dstTags[tag],
+		})
+	}
+
+	for tag, digest := range srcTags {
+		dstDigest, ok := dstTags[tag]
+		if !ok {
+			add(tag, models.DriftMissing)
+		} else if dstDigest != digest {
+			add(tag, models.DriftStale)
+		}
+	}
+	for tag := range dstTags {
+		if _, ok := srcTags[tag]; !ok {
+			add(tag, models.DriftExtra)
+		}
+	}
+
+	return drifts
+}
+
+// enqueueRepairJobs creates a transfer job for every repository which has missing or stale tags,
+// the extra tags on the destination are only reported.
+func (v *Verifier) enqueueRepairJobs(drifts []*models.RepDrift) error {
+	repos, tags := groupDrifts(drifts, models.DriftMissing, models.DriftStale)
+
+	for _, repo := range repos {
+		id, err := v.addJob(models.RepJob{
+			Repository: repo,
+			PolicyID:   v.policy.ID,
+			Operation:  models.RepOpTransfer,
+			TagList:    tags[repo],
+		})
+		if err != nil {
+			v.logger.Errorf("an error occurred while creating repair job for %s: %v", repo, err)
+			return err
+		}
+		v.logger.Infof("repair job %d for %s:%v created", id, repo, tags[repo])
+	}
+
+	return nil
+}
+
+// enqueuePruneJobs creates a delete job for every repository which has extra tags on the destination.
+// Nothing is deleted if the extra tags exceed the threshold of the policy, as it is more likely caused
+// by a mistake, e.g. a wrong filter, than by tags removed at the source.
+func (v *Verifier) enqueuePruneJobs(drifts []*models.RepDrift, total int) error {
+	repos, tags := groupDrifts(drifts, models.DriftExtra)
+
+	extra := 0
+	for _, repo := range repos {
+		extra += len(tags[repo])
+	}
+	if extra == 0 {
+		v.logger.Infof("no tag needs to be pruned from %s", v.remoteURL)
+		return nil
+	}
+
+	threshold := v.policy.PruneThreshold()
+	if !pruneAllowed(extra, total, threshold) {
+		v.logger.Errorf("%d of %d tags on %s would be pruned, which exceeds the threshold %d%%, pruning aborted",
+			extra, total, v.remoteURL, threshold)
+		return fmt.Errorf("%d of %d tags would be pruned, which exceeds the threshold %d%%", extra, total, threshold)
+	}
+
+	for _, repo := range repos {
+		id, err := v.addJob(models.RepJob{
+			Repository: repo,
+			PolicyID:   v.policy.ID,
+			Operation:  models.RepOpDelete,
+			TagList:    tags[repo],
+		})
+		if err != nil {
+			v.logger.Errorf("an error occurred while creating prune job for %s: %v", repo, err)
+			return err
+		}
+		v.logger.Infof("prune job %d for %s:%v created", id, repo, tags[repo])
+	}
+
+	return nil
+}
+
+// PlanPrune returns the tags which would be pruned from the destination, nothing is deleted
+func (v *Verifier) PlanPrune() (*models.RepPlanPrune, error) {
+	drifts, total, err := v.diff()
+	if err != nil {
+		return nil, err
+	}
+
+	prune := &models.RepPlanPrune{
+		Repositories: []*models.RepPlanPruneRepository{},
+		TagsTotal:    total,
+	}
+	repos, tags := groupDrifts(drifts, models.DriftExtra)
+	for _, repo := range repos {
+		prune.Repositories = append(prune.Repositories, &models.RepPlanPruneRepository{
+			Name: repo,
+			Tags: tags[repo],
+		})
+		prune.TagsToPrune += len(tags[repo])
+	}
+	prune.Aborted = !pruneAllowed(prune.TagsToPrune, total, v.policy.PruneThreshold())
+	return prune, nil
+}
+
+// pruneAllowed returns whether extra of the total tags can be pruned with the threshold in percent
+func pruneAllowed(extra, total, threshold int) bool {
+	return extra*100 <= total*threshold
+}
+
+// groupDrifts groups the tags of the differences of the kinds by repository, the
+// repositories are returned in the order they first appear
+func groupDrifts(drifts []*models.RepDrift, kinds ...string) ([]string, map[string][]string) {
+	tags := make(map[string][]string)
+	var repos []string
+	for _, drift := range drifts {
+		matched := false
+		for _, kind := range kinds {
+			if drift.Kind == kind {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if _, ok := tags[drift.Repository]; !ok {
+			repos = append(repos, drift.Repository)
+		}
+		tags[drift.Repository] = append(tags[drift.Repository], drift.Tag)
+	}
+	return repos, tags
+}
@@ -0,0 +1,172 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package replication
+
+import (
+	"io/ioutil"
+	"sort"
+	"testing"
+
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/log"
+)
+
+func TestCompare(t *testing.T) {
+	v := &Verifier{
+		jobID:  1,
+		policy: &models.RepPolicy{ID: 2},
+	}
+
+	src := map[string]string{
+		"v1": "sha256:1",
+		"v2": "sha256:2",
+		"v3": "sha256:3",
+	}
+	dst := map[string]string{
+		"v1": "sha256:1",
+		"v2": "sha256:0",
+		"v4": "sha256:4",
+	}
+
+	drifts := v.compare("library/a", src, dst)
+	kinds := make(map[string]string)
+	for _, drift := range drifts {
+		if drift.JobID != 1 || drift.PolicyID != 2 || drift.Repository != "library/a" {
+			t.Errorf("unexpected drift: %+v", drift)
+		}
+		if drift.SrcDigest != src[drift.Tag] || drift.DstDigest != dst[drift.Tag] {
+			t.Errorf("unexpected digests of drift: %+v", drift)
+		}
+		kinds[drift.Tag] = drift.Kind
+	}
+
+	expected := map[string]string{
+		"v2": models.DriftStale,
+		"v3": models.DriftMissing,
+		"v4": models.DriftExtra,
+	}
+	if len(kinds) != len(expected) {
+		t.Fatalf("unexpected drifts: %v, expected: %v", kinds, expected)
+	}
+	for tag, kind := range expected {
+		if kinds[tag] != kind {
+			t.Errorf("unexpected kind of drift of tag %s: %s, expected: %s", tag, kinds[tag], kind)
+		}
+	}
+
+	if drifts := v.compare("library/a", src, src); len(drifts) != 0 {
+		t.Errorf("unexpected drifts between the same tags: %v", drifts)
+	}
+}
+
+func TestEnqueueRepairJobs(t *testing.T) {
+	var jobs []models.RepJob
+	v := &Verifier{
+		policy: &models.RepPolicy{ID: 1},
+		addJob: func(job models.RepJob) (int64, error) {
+			jobs = append(jobs, job)
+			return int64(len(jobs)), nil
+		},
+		logger: log.New(ioutil.Discard, log.NewTextFormatter(), log.ErrorLevel),
+	}
+
+	drifts := []*models.RepDrift{
+		{Repository: "library/a", Tag: "v1", Kind: models.DriftMissing},
+		{Repository: "library/b", Tag: "v1", Kind: models.DriftExtra},
+		{Repository: "library/a", Tag: "v2", Kind: models.DriftStale},
+		{Repository: "library/c", Tag: "v1", Kind: models.DriftStale},
+	}
+
+	if err := v.enqueueRepairJobs(drifts); err != nil {
+		t.Fatalf("failed to enqueue repair jobs: %v", err)
+	}
+
+	// the extra tags are only reported
+	tags := make(map[string][]string)
+	for _, job := range jobs {
+		if job.PolicyID != 1 || job.Operation != models.RepOpTransfer {
+			t.Errorf("unexpected repair job: %+v", job)
+		}
+		sort.Strings(job.TagList)
+		tags[job.Repository] = job.TagList
+	}
+	if len(jobs) != 2 || len(tags["library/a"]) != 2 || len(tags["library/c"]) != 1 {
+		t.Errorf("unexpected repair jobs: %+v", jobs)
+	}
+}
+
+func TestEnqueuePruneJobs(t *testing.T) {
+	extra := func(repository string, tags ...string) []*models.RepDrift {
+		var drifts []*models.RepDrift
+		for _, tag := range tags {
+			drifts = append(drifts, &models.RepDrift{
+				Repository: repository,
+				Tag:        tag,
+				Kind:       models.DriftExtra,
+			})
+		}
+		return drifts
+	}
+
+	cases := []struct {
+		drifts    []*models.RepDrift
+		total     int
+		threshold int // 0 means the default threshold
+		jobs      int // the number of prune jobs created
+		err       bool
+	}{
+		// nothing to prune
+		{nil, 10, 0, 0, false},
+		{[]*models.RepDrift{{Repository: "library/a", Tag: "v1", Kind: models.DriftMissing}}, 10, 0, 0, false},
+		// 5 of 10 is just the default threshold 50%
+		{append(extra("library/a", "v1", "v2", "v3"), extra("library/b", "v1", "v2")...), 10, 0, 2, false},
+		// 6 of 10 exceeds the default threshold
+		{append(extra("library/a", "v1", "v2", "v3"), extra("library/b", "v1", "v2", "v3")...), 10, 0, 0, true},
+		// 2 of 10 exceeds the threshold 10%
+		{extra("library/a", "v1", "v2"), 10, 10, 0, true},
+		{extra("library/a", "v1"), 10, 10, 1, false},
+		// all the tags can be pruned with the threshold 100%
+		{extra("library/a", "v1", "v2"), 2, 100, 1, false},
+	}
+
+	for i, c := range cases {
+		var jobs []models.RepJob
+		v := &Verifier{
+			policy: &models.RepPolicy{
+				ID:              1,
+				MirrorThreshold: c.threshold,
+			},
+			addJob: func(job models.RepJob) (int64, error) {
+				jobs = append(jobs, job)
+				return int64(len(jobs)), nil
+			},
+			logger: log.New(ioutil.Discard, log.NewTextFormatter(), log.ErrorLevel),
+		}
+
+		err := v.enqueuePruneJobs(c.drifts, c.total)
+		if c.err != (err != nil) {
+			t.Errorf("unexpected error of case %d: %v", i, err)
+		}
+		if len(jobs) != c.jobs {
+			t.Errorf("unexpected prune jobs of case %d: %+v, expected %d jobs", i, jobs, c.jobs)
+		}
+		for _, job := range jobs {
+			if job.Operation != models.RepOpDelete || len(job.TagList) == 0 {
+				t.Errorf("unexpected prune job of case %d: %+v", i, job)
+			}
+		}
+	}
+}
@@ -16,21 +16,145 @@
 package job
 
 import (
-	"github.com/vmware/harbor/utils/log"
+	"math"
 	"time"
+
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/job/config"
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/log"
 )
 
-var jobQueue = make(chan int64)
+const (
+	// the interval to poll the queue when there is no notification
+	pollInterval = 5 * time.Second
+	// a job will be claimed by other nodes if its lease is not renewed in this period
+	leaseDuration = 1 * time.Minute
+	// the interval to renew the leases of the jobs owned by this node
+	heartbeatInterval = 20 * time.Second
+)
 
-// Schedule put a job id into job queue.
+// notification to the dispatcher that new jobs have been put into the queue,
+// the buffer makes sure Schedule never blocks and repeated notifications are merged
+var jobQueue = make(chan struct{}, 1)
+
+// Schedule notifies the dispatcher that a job has been put into the queue in DB.
 func Schedule(jobID int64) {
-	jobQueue <- jobID
+	log.Debugf("Job %d is put into the queue", jobID)
+	notifyDispatcher()
+}
+
+// notifyDispatcher wakes up the dispatcher if it is waiting for jobs, e.g. a job has been put
+// into the queue or a running job has ended so the jobs blocked by the concurrency limits may run.
+func notifyDispatcher() {
+	select {
+	case jobQueue <- struct{}{}:
+	default:
+	}
+}
+
+// claimJob claims the next job from the queue, it returns nil if the queue is empty.
+// The jobs for the targets which are out of their time windows and the jobs for the
+// policies or targets which have reached their concurrency limits wait in the queue,
+// the other jobs are claimed over them.
+func claimJob() *models.RepJob {
+	policies, targets := blockedJobs()
+	job, err := dao.ClaimRepJob(config.NodeID(), leaseDuration, policies, targets)
+	if err != nil {
+		log.Errorf("Failed to claim job from queue, error: %v", err)
+		return nil
+	}
+	return job
+}
+
+// blockedJobs returns the IDs of policies and targets whose jobs are not allowed to run now
+func blockedJobs() ([]int64, []int64) {
+	policies, err := dao.GetRepPoliciesAtJobLimit()
+	if err != nil {
+		log.Errorf("Failed to get policies at their concurrency limits, error: %v", err)
+	}
+
+	targets, err := dao.GetRepTargetsAtJobLimit()
+	if err != nil {
+		log.Errorf("Failed to get targets at their concurrency limits, error: %v", err)
+	}
+
+	return policies, append(targets, blockedTargets()...)
+}
+
+var (
+	// the targets out of their time windows are cached for a poll interval, as jobs are
+	// claimed one by one and the windows are minute-grained
+	blockedTargetsCache      []int64
+	blockedTargetsUpdateTime time.Time
+)
+
+// blockedTargets returns the IDs of targets which are out of their time windows. It's only
+// called by the dispatcher, so the cache needs no lock.
+func blockedTargets() []int64 {
+	now := time.Now()
+	if now.Sub(blockedTargetsUpdateTime) < pollInterval {
+		return blockedTargetsCache
+	}
+
+	targets, err := dao.FilterRepTargets("")
+	if err != nil {
+		log.Errorf("Failed to get targets, error: %v", err)
+		return nil
+	}
+
+	var blocked []int64
+	for _, target := range targets {
+		if !target.InTimeWindow(now) {
+			blocked = append(blocked, target.ID)
+		}
+	}
+
+	blockedTargetsCache = blocked
+	blockedTargetsUpdateTime = now
+	return blocked
+}
+
+// Heartbeat renews the leases of the jobs owned by this node periodically, so that they will not
+// be claimed by other nodes, and stops the jobs owned by this node which are requested to stop
+// via any node.
+func Heartbeat() {
+	for range time.Tick(heartbeatInterval) {
+		if err := dao.RenewRepJobLeases(config.NodeID(), leaseDuration); err != nil {
+			log.Errorf("Failed to renew the leases of jobs, error: %v", err)
+		}
+
+		ids, err := dao.GetRepJobIDsToStop(config.NodeID())
+		if err != nil {
+			log.Errorf("Failed to get the jobs requested to stop, error: %v", err)
+			continue
+		}
+		if len(ids) > 0 {
+			WorkerPool.StopJobs(ids)
+		}
+	}
+}
+
+// releaseJob releases the lease of the job after it's handled by a worker.
+func releaseJob(jobID int64) {
+	if err := dao.ReleaseRepJob(jobID, config.NodeID()); err != nil {
+		log.Errorf("Failed to release the lease of job %d, error: %v", jobID, err)
+	}
+	notifyDispatcher()
+}
+
+// retryExhausted returns whether the job which has been retried n times can not be retried any more,
+// the attempts are unlimited if max is 0.
+func retryExhausted(n, max int) bool {
+	return max > 0 && n >= max
 }
 
-// Reschedule is called by statemachine to retry a job
-func Reschedule(jobID int64) {
-	log.Debugf("Job %d will be rescheduled in 5 minutes", jobID)
-	time.Sleep(5 * time.Minute)
-	log.Debugf("Rescheduling job %d", jobID)
-	Schedule(jobID)
+// retryDelay calculates the delay before the nth retry, the delay grows exponentially from
+// the initial delay by the multiplier and is capped by the max delay.
+func retryDelay(n int, initial time.Duration, multiplier float64, max time.Duration) time.Duration {
+	d := float64(initial) * math.Pow(multiplier, float64(n-1))
+	if d > float64(max) {
+		return max
+	}
+	return time.Duration(d)
 }
@@ -0,0 +1,72 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cases := []struct {
+		n          int
+		initial    time.Duration
+		multiplier float64
+		max        time.Duration
+		delay      time.Duration
+	}{
+		{1, 5 * time.Minute, 2, 1 * time.Hour, 5 * time.Minute},
+		{2, 5 * time.Minute, 2, 1 * time.Hour, 10 * time.Minute},
+		{4, 5 * time.Minute, 2, 1 * time.Hour, 40 * time.Minute},
+		// capped by the max delay
+		{5, 5 * time.Minute, 2, 1 * time.Hour, 1 * time.Hour},
+		{100, 5 * time.Minute, 2, 1 * time.Hour, 1 * time.Hour},
+		// the delay is constant if the multiplier is 1
+		{10, 1 * time.Minute, 1, 1 * time.Hour, 1 * time.Minute},
+		{3, 10 * time.Second, 1.5, 1 * time.Minute, 22500 * time.Millisecond},
+	}
+
+	for _, c := range cases {
+		if delay := retryDelay(c.n, c.initial, c.multiplier, c.max); delay != c.delay {
+			t.Errorf("unexpected delay of retry %d with initial delay %v, multiplier %v and max delay %v: %v, expected: %v",
+				c.n, c.initial, c.multiplier, c.max, delay, c.delay)
+		}
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	cases := []struct {
+		n         int
+		max       int
+		exhausted bool
+	}{
+		{0, 10, false},
+		{9, 10, false},
+		{10, 10, true},
+		{11, 10, true},
+		{0, 1, false},
+		{1, 1, true},
+		// no limit
+		{1000, 0, false},
+	}
+
+	for _, c := range cases {
+		if exhausted := retryExhausted(c.n, c.max); exhausted != c.exhausted {
+			t.Errorf("unexpected result for the job retried %d times with max attempts %d: %v, expected: %v",
+				c.n, c.max, exhausted, c.exhausted)
+		}
+	}
+}
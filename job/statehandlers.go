@@ -16,9 +16,11 @@
 package job
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/job/config"
 	"github.com/vmware/harbor/models"
 	"github.com/vmware/harbor/utils/log"
 )
@@ -59,20 +61,38 @@ func (su StatusUpdater) Exit() error {
 	return nil
 }
 
-// Retry handles a special "retrying" in which case it will update the status in DB and reschedule the job
-// via scheduler
+// Retry handles a special "retrying" in which case it will update the status and the retry count in DB,
+// the job will be claimed from the queue again after a delay which grows exponentially. If the retry
+// attempts are exhausted, the job will be moved to error.
 type Retry struct {
-	JobID int64
+	JobID  int64
+	Logger *log.Logger
 }
 
 // Enter ...
 func (jr Retry) Enter() (string, error) {
-	err := dao.UpdateRepJobStatus(jr.JobID, models.JobRetrying)
+	job, err := dao.GetRepJob(jr.JobID)
 	if err != nil {
+		log.Errorf("Failed to get job: %d, error: %v", jr.JobID, err)
+		return "", err
+	}
+	if job == nil {
+		return "", fmt.Errorf("The job doesn't exist in DB, job id: %d", jr.JobID)
+	}
+
+	if retryExhausted(job.RetryCount, config.RetryMaxAttempts()) {
+		jr.Logger.Errorf("The job has been retried %d times, will not retry any more", job.RetryCount)
+		return models.JobError, nil
+	}
+
+	n := job.RetryCount + 1
+	delay := retryDelay(n, config.RetryInitialDelay(), config.RetryMultiplier(), config.RetryMaxDelay())
+	if err = dao.UpdateRepJobRetry(jr.JobID, n, delay); err != nil {
 		log.Errorf("Failed to update state of job :%d to Retrying, error: %v", jr.JobID, err)
+		return "", err
 	}
-	go Reschedule(jr.JobID)
-	return "", err
+	jr.Logger.Infof("The job will be retried in %v, attempt: %d", delay, n)
+	return "", nil
 }
 
 // Exit ...
@@ -80,6 +100,28 @@ func (jr Retry) Exit() error {
 	return nil
 }
 
+// Interrupter handles the special "interrupted" state, it puts the job back to the queue when the job
+// service is shutting down. The job will be resumed from its checkpoint by this or another node.
+type Interrupter struct {
+	JobID  int64
+	Logger *log.Logger
+}
+
+// Enter ...
+func (ji Interrupter) Enter() (string, error) {
+	if err := dao.UpdateRepJobStatus(ji.JobID, models.JobPending); err != nil {
+		log.Errorf("Failed to update state of job :%d to Pending, error: %v", ji.JobID, err)
+		return "", err
+	}
+	ji.Logger.Info("The job is interrupted as job service is shutting down, it will be resumed from the checkpoint")
+	return "", nil
+}
+
+// Exit ...
+func (ji Interrupter) Exit() error {
+	return nil
+}
+
 // ImgPuller was for testing
 type ImgPuller struct {
 	img    string
@@ -28,17 +28,27 @@ import (
 	"github.com/vmware/harbor/utils/log"
 )
 
+// stateInterrupted is the state that the SM enters when the job service is shutting down,
+// the job is put back to the queue and will be resumed from its checkpoint.
+const stateInterrupted = "_interrupted"
+
 // RepJobParm wraps the parm of a job
 type RepJobParm struct {
 	LocalRegURL    string
 	TargetURL      string
 	TargetUsername string
 	TargetPassword string
+	TargetType     int
+	BandwidthLimit int64
 	Repository     string
 	Tags           []string
 	Enabled        int
 	Operation      string
+	ExecutionID    int64
 	Insecure       bool
+	Policy         *models.RepPolicy
+	// the job is resumed from a checkpoint, Tags are the remaining ones and no tag is left if it's empty
+	Resumed bool
 }
 
 // SM is the state machine to handle job, it handles one job at a time.
@@ -52,8 +62,13 @@ type SM struct {
 	Handlers     map[string]StateHandler
 	desiredState string
 	Logger       *log.Logger
-	Parms        *RepJobParm
-	lock         *sync.Mutex
+	// the parameters of replication jobs
+	Parms *RepJobParm
+	// the parameters loaded by the kinds of jobs other than replication
+	KindParms interface{}
+	// set when the job should be canceled instead of being handled
+	Canceled bool
+	lock     *sync.Mutex
 }
 
 // EnterState transit the statemachine from the current state to the state in parameter.
@@ -158,6 +173,18 @@ func (sm *SM) Stop(id int64) {
 	}
 }
 
+// Interrupt sets the desired state as "interrupted" so that the job will be put back to the queue when the next
+// transition happens, it is a safe point as the blobs and manifests are transferred within a single state.
+// It does not override the stop request.
+func (sm *SM) Interrupt() {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	if len(sm.desiredState) == 0 {
+		sm.desiredState = stateInterrupted
+		log.Debugf("Desired state of job %d is set to interrupted", sm.JobID)
+	}
+}
+
 func (sm *SM) getDesiredState() string {
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
@@ -180,10 +207,12 @@ func (sm *SM) Init() {
 		models.JobStopped:  struct{}{},
 		models.JobCanceled: struct{}{},
 		models.JobRetrying: struct{}{},
+		stateInterrupted:   struct{}{},
 	}
 }
 
-// Reset resets the state machine so it will start handling another job.
+// Reset resets the state machine so it will start handling another job. The parameters and
+// transitions of the job are set up by the kind of the job.
 func (sm *SM) Reset(jid int64) error {
 	//To ensure the new jobID is visible to the thread to stop the SM
 	sm.lock.Lock()
@@ -192,7 +221,9 @@ func (sm *SM) Reset(jid int64) error {
 	sm.lock.Unlock()
 
 	sm.Logger = utils.NewLogger(sm.JobID)
-	//init parms
+	sm.Parms = nil
+	sm.KindParms = nil
+	sm.Canceled = false
 	job, err := dao.GetRepJob(sm.JobID)
 	if err != nil {
 		return fmt.Errorf("Failed to get job, error: %v", err)
@@ -200,66 +231,94 @@ func (sm *SM) Reset(jid int64) error {
 	if job == nil {
 		return fmt.Errorf("The job doesn't exist in DB, job id: %d", sm.JobID)
 	}
+	kind := GetKind(job.Operation)
+	if kind == nil {
+		return fmt.Errorf("unsupported operation: %s", job.Operation)
+	}
+
+	//init parms
+	enabled, err := kind.Load(sm, job)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		//worker will cancel this job
+		sm.Canceled = true
+		return nil
+	}
+
+	//init states handlers
+	sm.Handlers = make(map[string]StateHandler)
+	sm.Transitions = make(map[string]map[string]struct{})
+	sm.CurrentState = models.JobPending
+
+	sm.AddTransition(models.JobPending, models.JobRunning, StatusUpdater{sm.JobID, models.JobRunning})
+	sm.AddTransition(models.JobRetrying, models.JobRunning, StatusUpdater{sm.JobID, models.JobRunning})
+	sm.Handlers[models.JobError] = StatusUpdater{sm.JobID, models.JobError}
+	sm.Handlers[models.JobStopped] = StatusUpdater{sm.JobID, models.JobStopped}
+	sm.Handlers[models.JobRetrying] = Retry{JobID: sm.JobID, Logger: sm.Logger}
+	sm.Handlers[stateInterrupted] = Interrupter{JobID: sm.JobID, Logger: sm.Logger}
+
+	kind.AddTransitions(sm)
+	return nil
+}
+
+// loadRepJobParms loads the policy and target of the replication job, it returns false if the
+// policy is disabled.
+func loadRepJobParms(sm *SM, job *models.RepJob) (bool, error) {
 	policy, err := dao.GetRepPolicy(job.PolicyID)
 	if err != nil {
-		return fmt.Errorf("Failed to get policy, error: %v", err)
+		return false, fmt.Errorf("Failed to get policy, error: %v", err)
 	}
 	if policy == nil {
-		return fmt.Errorf("The policy doesn't exist in DB, policy id:%d", job.PolicyID)
+		return false, fmt.Errorf("The policy doesn't exist in DB, policy id:%d", job.PolicyID)
+	}
+	tags := job.TagList
+	remaining, resumed, err := job.RemainingTags()
+	if err != nil {
+		return false, fmt.Errorf("Failed to parse the checkpoint of job %d, error: %v", job.ID, err)
+	}
+	if resumed {
+		tags = remaining
+		sm.Logger.Infof("resuming from the checkpoint, remaining tags: %v", tags)
 	}
 	sm.Parms = &RepJobParm{
 		LocalRegURL: config.LocalRegURL(),
 		Repository:  job.Repository,
-		Tags:        job.TagList,
+		Tags:        tags,
 		Enabled:     policy.Enabled,
 		Operation:   job.Operation,
+		ExecutionID: job.ExecutionID,
 		Insecure:    !config.VerifyRemoteCert(),
+		Policy:      policy,
+		Resumed:     resumed,
 	}
-	if policy.Enabled == 0 {
-		//worker will cancel this job
-		return nil
+	// plan jobs only dry run the policy, so a policy can be planned before it's enabled
+	if policy.Enabled == 0 && job.Operation != models.RepOpPlan {
+		return false, nil
 	}
 	target, err := dao.GetRepTarget(policy.TargetID)
 	if err != nil {
-		return fmt.Errorf("Failed to get target, error: %v", err)
+		return false, fmt.Errorf("Failed to get target, error: %v", err)
 	}
 	if target == nil {
-		return fmt.Errorf("The target doesn't exist in DB, target id: %d", policy.TargetID)
+		return false, fmt.Errorf("The target doesn't exist in DB, target id: %d", policy.TargetID)
 	}
 	sm.Parms.TargetURL = target.URL
 	sm.Parms.TargetUsername = target.Username
+	sm.Parms.TargetType = target.Type
+	sm.Parms.BandwidthLimit = target.BandwidthLimit
 	pwd := target.Password
 
 	if len(pwd) != 0 {
 		pwd, err = uti.ReversibleDecrypt(pwd)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt password: %v", err)
+			return false, fmt.Errorf("failed to decrypt password: %v", err)
 		}
 	}
 
 	sm.Parms.TargetPassword = pwd
-
-	//init states handlers
-	sm.Handlers = make(map[string]StateHandler)
-	sm.Transitions = make(map[string]map[string]struct{})
-	sm.CurrentState = models.JobPending
-
-	sm.AddTransition(models.JobPending, models.JobRunning, StatusUpdater{sm.JobID, models.JobRunning})
-	sm.AddTransition(models.JobRetrying, models.JobRunning, StatusUpdater{sm.JobID, models.JobRunning})
-	sm.Handlers[models.JobError] = StatusUpdater{sm.JobID, models.JobError}
-	sm.Handlers[models.JobStopped] = StatusUpdater{sm.JobID, models.JobStopped}
-	sm.Handlers[models.JobRetrying] = Retry{sm.JobID}
-
-	switch sm.Parms.Operation {
-	case models.RepOpTransfer:
-		addImgTransferTransition(sm)
-	case models.RepOpDelete:
-		addImgDeleteTransition(sm)
-	default:
-		err = fmt.Errorf("unsupported operation: %s", sm.Parms.Operation)
-	}
-
-	return err
+	return true, nil
 }
 
 //for testing onlly
@@ -269,23 +328,60 @@ func addTestTransition(sm *SM) error {
 }
 
 func addImgTransferTransition(sm *SM) {
-	base := replication.InitBaseHandler(sm.Parms.Repository, sm.Parms.LocalRegURL, config.UISecret(),
-		sm.Parms.TargetURL, sm.Parms.TargetUsername, sm.Parms.TargetPassword,
-		sm.Parms.Insecure, sm.Parms.Tags, sm.Logger)
+	// all the tags were replicated before the job was interrupted, an empty list
+	// of tags would make the job replicate all the tags of the repository
+	if sm.Parms.Resumed && len(sm.Parms.Tags) == 0 {
+		sm.AddTransition(models.JobRunning, models.JobFinished, &StatusUpdater{sm.JobID, models.JobFinished})
+		return
+	}
+
+	base := replication.InitBaseHandler(sm.JobID, sm.Parms.Policy, sm.Parms.Repository, sm.Parms.LocalRegURL, config.UISecret(),
+		sm.Parms.TargetURL, sm.Parms.TargetUsername, sm.Parms.TargetPassword, sm.Parms.TargetType,
+		sm.Parms.BandwidthLimit, sm.Parms.Insecure, sm.Parms.Tags, config.MaxBlobTransfers(), config.BlobChunkSize(), sm.Logger)
 
 	sm.AddTransition(models.JobRunning, replication.StateInitialize, &replication.Initializer{BaseHandler: base})
 	sm.AddTransition(replication.StateInitialize, replication.StateCheck, &replication.Checker{BaseHandler: base})
 	sm.AddTransition(replication.StateCheck, replication.StatePullManifest, &replication.ManifestPuller{BaseHandler: base})
 	sm.AddTransition(replication.StatePullManifest, replication.StateTransferBlob, &replication.BlobTransfer{BaseHandler: base})
 	sm.AddTransition(replication.StatePullManifest, models.JobFinished, &StatusUpdater{sm.JobID, models.JobFinished})
+	// the tags skipped because of the conflict mode of the policy
+	sm.AddTransition(replication.StatePullManifest, replication.StatePullManifest, &replication.ManifestPuller{BaseHandler: base})
 	sm.AddTransition(replication.StateTransferBlob, replication.StatePushManifest, &replication.ManifestPusher{BaseHandler: base})
 	sm.AddTransition(replication.StatePushManifest, replication.StatePullManifest, &replication.ManifestPuller{BaseHandler: base})
 }
 
 func addImgDeleteTransition(sm *SM) {
-	deleter := replication.NewDeleter(sm.Parms.Repository, sm.Parms.Tags, sm.Parms.TargetURL,
-		sm.Parms.TargetUsername, sm.Parms.TargetPassword, sm.Parms.Insecure, sm.Logger)
+	// the repository is deleted under the name which it is replicated to on the target
+	repository := sm.Parms.Policy.RemoteRepository(sm.Parms.Repository)
+	deleter := replication.NewDeleter(sm.Parms.Policy, repository, sm.Parms.Tags, sm.Parms.LocalRegURL,
+		config.UISecret(), sm.Parms.Repository, sm.Parms.TargetURL, sm.Parms.TargetUsername,
+		sm.Parms.TargetPassword, sm.Parms.TargetType, sm.Parms.Insecure, sm.Logger)
 
 	sm.AddTransition(models.JobRunning, replication.StateDelete, deleter)
 	sm.AddTransition(replication.StateDelete, models.JobFinished, &StatusUpdater{sm.JobID, models.JobFinished})
 }
+
+func addVerifyTransition(sm *SM) {
+	policy := sm.Parms.Policy
+	localRepos := func() ([]string, error) {
+		return utils.GetRepoList(policy.ProjectID)
+	}
+	remoteRepos := func() ([]string, error) {
+		return getRemoteRepoList(policy)
+	}
+	// the transfer jobs created for repairing belong to the same execution as the verification job
+	executionID := sm.Parms.ExecutionID
+	addJob := func(j models.RepJob) (int64, error) {
+		j.ExecutionID = executionID
+		return AddRepJob(j)
+	}
+
+	verifier := replication.NewVerifier(sm.JobID, policy, sm.Parms.LocalRegURL, config.UISecret(),
+		sm.Parms.TargetURL, sm.Parms.TargetUsername, sm.Parms.TargetPassword, sm.Parms.Insecure,
+		config.MaxBlobTransfers(), sm.Parms.Operation == models.RepOpRepair,
+		sm.Parms.Operation == models.RepOpPrune, localRepos, remoteRepos,
+		addJob, sm.Logger)
+
+	sm.AddTransition(models.JobRunning, replication.StateVerify, verifier)
+	sm.AddTransition(replication.StateVerify, models.JobFinished, &StatusUpdater{sm.JobID, models.JobFinished})
+}
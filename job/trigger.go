@@ -0,0 +1,171 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+
+	"github.com/vmware/harbor/dao"
+	"github.com/vmware/harbor/job/config"
+	"github.com/vmware/harbor/job/utils"
+	"github.com/vmware/harbor/models"
+	uti "github.com/vmware/harbor/utils"
+	"github.com/vmware/harbor/utils/log"
+)
+
+// AddRepJob inserts a replication job into the queue in DB and notifies the scheduler.
+func AddRepJob(j models.RepJob) (int64, error) {
+	log.Debugf("Creating job for repo: %s, policy: %d", j.Repository, j.PolicyID)
+	id, err := dao.AddRepJob(j)
+	if err != nil {
+		return 0, err
+	}
+	log.Debugf("Send job to scheduler, job id: %d", id)
+	Schedule(id)
+	return id, nil
+}
+
+// AddRepExecution creates an execution of the policy for a trigger, the jobs created by the
+// trigger should be linked to it.
+func AddRepExecution(policyID int64, trigger string) (int64, error) {
+	id, err := dao.AddRepExecution(models.RepExecution{
+		PolicyID: policyID,
+		Trigger:  trigger,
+	})
+	if err != nil {
+		return 0, err
+	}
+	log.Debugf("Execution %d of policy %d is created, trigger: %s", id, policyID, trigger)
+	return id, nil
+}
+
+// SyncPolicy creates an execution of the policy and a transfer job for every repository of the project
+// which the policy is associated with. For pull-mode policies, the repositories are listed from the target.
+// If the policy is in mirror mode, a prune job is also created to remove the tags which no longer exist
+// at the source from the target. It returns the ID of the execution.
+func SyncPolicy(policy *models.RepPolicy, trigger string) (int64, error) {
+	repoList, err := getPolicyRepoList(policy)
+	if err != nil {
+		log.Errorf("Failed to get repository list, policy id: %d, error: %v", policy.ID, err)
+		return 0, err
+	}
+	log.Debugf("repo list: %v", repoList)
+	executionID, err := AddRepExecution(policy.ID, trigger)
+	if err != nil {
+		log.Errorf("Failed to insert execution record, error: %v", err)
+		return 0, err
+	}
+	for _, repo := range repoList {
+		j := models.RepJob{
+			Repository:  repo,
+			PolicyID:    policy.ID,
+			ExecutionID: executionID,
+			Operation:   models.RepOpTransfer,
+		}
+		if _, err := AddRepJob(j); err != nil {
+			log.Errorf("Failed to insert job record, error: %v", err)
+			return 0, err
+		}
+	}
+	if policy.Mirror == 1 && policy.Direction != models.RepDirectionPull {
+		j := models.RepJob{
+			PolicyID:    policy.ID,
+			ExecutionID: executionID,
+			Operation:   models.RepOpPrune,
+		}
+		if _, err := AddRepJob(j); err != nil {
+			log.Errorf("Failed to insert prune job record, error: %v", err)
+			return 0, err
+		}
+	}
+	return executionID, nil
+}
+
+// getPolicyRepoList returns the repositories which match the filters of the policy
+func getPolicyRepoList(policy *models.RepPolicy) ([]string, error) {
+	var repoList []string
+	var err error
+	if policy.Direction == models.RepDirectionPull {
+		repoList, err = getRemoteRepoList(policy)
+	} else {
+		repoList, err = utils.GetRepoList(policy.ProjectID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, repo := range repoList {
+		if !policy.MatchRepository(repo) {
+			log.Debugf("repository %s does not match the filters of policy %d, skip", repo, policy.ID)
+			continue
+		}
+		matched = append(matched, repo)
+	}
+	return matched, nil
+}
+
+// getRemoteRepoList lists the repositories of the target which are under the project of the policy,
+// the names of the repositories returned are the ones under the local project
+func getRemoteRepoList(policy *models.RepPolicy) ([]string, error) {
+	project, err := dao.GetProjectByID(policy.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, fmt.Errorf("project %d not found", policy.ProjectID)
+	}
+
+	target, err := getTarget(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteRepos, err := utils.GetRemoteRepoList(target.URL, target.Username, target.Password,
+		!config.VerifyRemoteCert(), policy.RemoteProject(project.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	// map the repositories back to the names under the local project, the ones
+	// which are not covered by the policy are skipped
+	var repos []string
+	for _, remoteRepo := range remoteRepos {
+		if repo, ok := policy.LocalRepository(project.Name, remoteRepo); ok {
+			repos = append(repos, repo)
+		}
+	}
+	return repos, nil
+}
+
+// getTarget returns the target of the policy whose password is decrypted
+func getTarget(policy *models.RepPolicy) (*models.RepTarget, error) {
+	target, err := dao.GetRepTarget(policy.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, fmt.Errorf("target %d not found", policy.TargetID)
+	}
+
+	if len(target.Password) != 0 {
+		if target.Password, err = uti.ReversibleDecrypt(target.Password); err != nil {
+			return nil, fmt.Errorf("failed to decrypt password: %v", err)
+		}
+	}
+
+	return target, nil
+}
@@ -17,6 +17,7 @@ package utils
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/vmware/harbor/job/config"
 	"github.com/vmware/harbor/utils/log"
@@ -64,3 +65,44 @@ func GetJobLogPath(jobID int64) string {
 	p = filepath.Join(config.LogDir(), p, f)
 	return p
 }
+
+// TailOffset returns the offset in the log file from which the last n lines start
+func TailOffset(f io.ReadSeeker, n int) (int64, error) {
+	size, err := f.Seek(0, os.SEEK_END)
+	if err != nil {
+		return 0, err
+	}
+
+	const blockSize = 4096
+	buf := make([]byte, blockSize)
+	end := size
+	lines := 0
+	for end > 0 {
+		start := end - blockSize
+		if start < 0 {
+			start = 0
+		}
+		block := buf[:end-start]
+		if _, err = f.Seek(start, os.SEEK_SET); err != nil {
+			return 0, err
+		}
+		if _, err = io.ReadFull(f, block); err != nil {
+			return 0, err
+		}
+		for i := len(block) - 1; i >= 0; i-- {
+			if block[i] != '\n' {
+				continue
+			}
+			// the newline at the end of file does not start a new line
+			if start+int64(i) == size-1 {
+				continue
+			}
+			lines++
+			if lines == n {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
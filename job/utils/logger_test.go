@@ -0,0 +1,65 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTailOffset(t *testing.T) {
+	long := strings.Repeat("x", 4095) + "\n"
+	longer := strings.Repeat("y", 5000) + "\n"
+
+	cases := []struct {
+		content string
+		n       int
+		offset  int64
+	}{
+		{"", 1, 0},
+		{"a\nb\nc\n", 1, 4},
+		{"a\nb\nc\n", 2, 2},
+		{"a\nb\nc\n", 3, 0},
+		{"a\nb\nc\n", 10, 0},
+		// no newline at the end of file
+		{"a\nb\nc", 1, 4},
+		{"a\nb\nc", 2, 2},
+		{"a\nb\nc", 3, 0},
+		// the last line is empty
+		{"a\n\n", 1, 2},
+		{"\n", 1, 0},
+		// the newline is at the boundary of blocks
+		{long + "b\n", 1, 4096},
+		{long + "b\n", 2, 0},
+		{"a\n" + long + long, 1, 4098},
+		{"a\n" + long + long, 2, 2},
+		// the lines span blocks
+		{longer + longer + longer, 1, 10002},
+		{longer + longer + longer, 2, 5001},
+		{longer + longer + "c", 1, 10002},
+	}
+
+	for i, c := range cases {
+		offset, err := TailOffset(strings.NewReader(c.content), c.n)
+		if err != nil {
+			t.Errorf("unexpected error of case %d: %v", i, err)
+			continue
+		}
+		if offset != c.offset {
+			t.Errorf("unexpected offset of the last %d lines of case %d: %d, expected: %d", c.n, i, offset, c.offset)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/harbor/job/config"
+	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/utils/log"
+	"github.com/vmware/harbor/utils/registry"
+	"github.com/vmware/harbor/utils/registry/auth"
+)
+
+// GetRepoList calls the api from UI to get repo list
+func GetRepoList(projectID int64) ([]string, error) {
+	uiURL := config.LocalUIURL()
+	client := &http.Client{}
+	req, err := http.NewRequest("GET", uiURL+"/api/repositories?project_id="+strconv.Itoa(int(projectID)), nil)
+	if err != nil {
+		log.Errorf("Error when creating request: %v", err)
+		return nil, err
+	}
+	req.AddCookie(&http.Cookie{Name: models.UISecretCookie, Value: config.UISecret()})
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Errorf("Error when calling UI api to get repositories, error: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Errorf("Unexpected status code: %d", resp.StatusCode)
+		dump, _ := httputil.DumpResponse(resp, true)
+		log.Debugf("response: %q", dump)
+		return nil, fmt.Errorf("Unexpected status code when getting repository list: %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Errorf("Failed to read the response body, error: %v", err)
+		return nil, err
+	}
+	var repoList []string
+	err = json.Unmarshal(body, &repoList)
+	return repoList, err
+}
+
+// GetRemoteRepoList lists the repositories under the project in the remote registry via catalog API
+func GetRemoteRepoList(endpoint, username, password string, insecure bool, project string) ([]string, error) {
+	credential := auth.NewBasicAuthCredential(username, password)
+	authorizer := auth.NewStandardTokenAuthorizer(credential, insecure, "registry", "catalog", "*")
+	store, err := auth.NewAuthorizerStore(endpoint, insecure, authorizer, auth.NewBasicAuthorizer(credential))
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := registry.NewRegistryWithModifiers(endpoint, insecure, store)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := client.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	var repoList []string
+	for _, repo := range repos {
+		if strings.HasPrefix(repo, project+"/") {
+			repoList = append(repoList, repo)
+		}
+	}
+	return repoList, nil
+}
@@ -16,6 +16,10 @@
 package job
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/vmware/harbor/dao"
 	"github.com/vmware/harbor/job/config"
 	"github.com/vmware/harbor/models"
@@ -25,6 +29,12 @@ import (
 type workerPool struct {
 	workerChan chan *Worker
 	workerList []*Worker
+	// the number of jobs dispatched but not yet handled by workers
+	busy sync.WaitGroup
+	// closed to stop the dispatcher, which closes dispatcherDone when it returns
+	quit           chan struct{}
+	dispatcherDone chan struct{}
+	shuttingDown   int32
 }
 
 // WorkerPool is a set of workers each worker is associate to a statemachine for handling jobs.
@@ -62,6 +72,8 @@ func (w *Worker) Start() {
 			case jobID := <-w.RepJobs:
 				log.Debugf("worker: %d, will handle job: %d", w.ID, jobID)
 				w.handleRepJob(jobID)
+				releaseJob(jobID)
+				WorkerPool.busy.Done()
 			case q := <-w.quit:
 				if q {
 					log.Debugf("worker: %d, will stop.", w.ID)
@@ -89,8 +101,13 @@ func (w *Worker) handleRepJob(id int64) {
 		}
 		return
 	}
-	if w.SM.Parms.Enabled == 0 {
-		log.Debugf("The policy of job:%d is disabled, will cancel the job", id)
+	// the job is left in the queue for other nodes or the next start
+	if WorkerPool.isShuttingDown() {
+		log.Debugf("Job service is shutting down, job: %d will not be handled", id)
+		return
+	}
+	if w.SM.Canceled {
+		log.Debugf("The job:%d is not enabled, will cancel the job", id)
 		_ = dao.UpdateRepJobStatus(id, models.JobCanceled)
 		w.SM.Logger.Info("The job has been canceled")
 	} else {
@@ -113,8 +130,10 @@ func NewWorker(id int) *Worker {
 // InitWorkerPool create workers according to configuration.
 func InitWorkerPool() {
 	WorkerPool = &workerPool{
-		workerChan: make(chan *Worker, config.MaxJobWorkers()),
-		workerList: make([]*Worker, 0, config.MaxJobWorkers()),
+		workerChan:     make(chan *Worker, config.MaxJobWorkers()),
+		workerList:     make([]*Worker, 0, config.MaxJobWorkers()),
+		quit:           make(chan struct{}),
+		dispatcherDone: make(chan struct{}),
 	}
 	for i := 0; i < config.MaxJobWorkers(); i++ {
 		worker := NewWorker(i)
@@ -124,16 +143,60 @@ func InitWorkerPool() {
 	}
 }
 
-// Dispatch will listen to the jobQueue of job service and try to pick a free worker from the worker pool and assign the job to it.
+// Dispatch takes a free worker from the worker pool, claims the next job from the queue in DB and assigns it
+// to the worker. When the queue is empty it waits for the notification from scheduler or polls the queue periodically,
+// so the jobs in memory are never more than the workers. It returns when the worker pool is shutting down.
 func Dispatch() {
+	defer close(WorkerPool.dispatcherDone)
 	for {
+		var worker *Worker
 		select {
-		case job := <-jobQueue:
-			go func(jobID int64) {
-				log.Debugf("Trying to dispatch job: %d", jobID)
-				worker := <-WorkerPool.workerChan
-				worker.RepJobs <- jobID
-			}(job)
+		case worker = <-WorkerPool.workerChan:
+		case <-WorkerPool.quit:
+			return
 		}
+		job := claimJob()
+		for job == nil {
+			select {
+			case <-jobQueue:
+			case <-time.After(pollInterval):
+			case <-WorkerPool.quit:
+				return
+			}
+			job = claimJob()
+		}
+		log.Debugf("Dispatching job: %d to worker: %d", job.ID, worker.ID)
+		WorkerPool.busy.Add(1)
+		worker.RepJobs <- job.ID
 	}
 }
+
+// Shutdown stops dispatching jobs and interrupts the running jobs at the next transition, the interrupted
+// jobs are put back to the queue and will be resumed from their checkpoints. It waits for the workers
+// until all the running jobs are interrupted or the timeout expires.
+func Shutdown(timeout time.Duration) {
+	atomic.StoreInt32(&WorkerPool.shuttingDown, 1)
+	close(WorkerPool.quit)
+	<-WorkerPool.dispatcherDone
+
+	for _, w := range WorkerPool.workerList {
+		w.SM.Interrupt()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		WorkerPool.busy.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info("All the running jobs have been interrupted")
+	case <-time.After(timeout):
+		log.Warningf("Timeout waiting for the running jobs to be interrupted after %v", timeout)
+	}
+}
+
+func (wp *workerPool) isShuttingDown() bool {
+	return atomic.LoadInt32(&wp.shuttingDown) == 1
+}
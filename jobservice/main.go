@@ -16,35 +16,46 @@
 package main
 
 import (
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/astaxie/beego"
 	"github.com/vmware/harbor/dao"
 	"github.com/vmware/harbor/job"
-	"github.com/vmware/harbor/models"
+	"github.com/vmware/harbor/job/config"
 	"github.com/vmware/harbor/utils/log"
 )
 
 func main() {
 	dao.InitDB()
 	initRouters()
+	resumeJobs()
 	job.InitWorkerPool()
 	go job.Dispatch()
-	resumeJobs()
+	go job.Heartbeat()
+	go job.SchedulePolicies()
+	go job.ScheduleCleanup()
+	go handleSignals()
 	beego.Run()
 }
 
+// handleSignals shuts down the job service gracefully when SIGTERM or SIGINT is received
+func handleSignals() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+	s := <-sig
+	log.Infof("Received signal %v, shutting down...", s)
+	job.Shutdown(config.ShutdownTimeout())
+	os.Exit(0)
+}
+
 func resumeJobs() {
 	log.Debugf("Trying to resume halted jobs...")
-	err := dao.ResetRunningJobs()
+	// the pending and retrying jobs are kept in the queue in DB and will be claimed by dispatcher,
+	// only the running jobs owned by this node need to be put back to the queue
+	err := dao.ResetRunningJobs(config.NodeID())
 	if err != nil {
 		log.Warningf("Failed to reset all running jobs to pending, error: %v", err)
 	}
-	jobs, err := dao.GetRepJobByStatus(models.JobPending, models.JobRetrying)
-	if err == nil {
-		for _, j := range jobs {
-			log.Debugf("Resuming job: %d", j.ID)
-			job.Schedule(j.ID)
-		}
-	} else {
-		log.Warningf("Failed to jobs to resume, error: %v", err)
-	}
 }
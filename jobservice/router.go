@@ -22,7 +22,11 @@ import (
 )
 
 func initRouters() {
+	beego.Router("/api/jobs", &api.Job{})
+	beego.Router("/api/jobs/kinds", &api.Job{}, "get:Kinds")
 	beego.Router("/api/jobs/replication", &api.ReplicationJob{})
 	beego.Router("/api/jobs/replication/:id/log", &api.ReplicationJob{}, "get:GetLog")
 	beego.Router("/api/jobs/replication/actions", &api.ReplicationJob{}, "post:HandleAction")
+	beego.Router("/api/jobs/replication/:id([0-9]+)/actions", &api.ReplicationJob{}, "post:HandleJobAction")
+	beego.Router("/api/jobs/replication/cleanup", &api.ReplicationJob{}, "post:Cleanup")
 }
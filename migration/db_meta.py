@@ -125,3 +125,71 @@ class ReplicationJob(Base):
     update_time = sa.Column(mysql.TIMESTAMP, server_default = sa.text("CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"))
     
     __table_args__ = (sa.Index('policy', "policy_id"),)
+
+class ReplicationDrift(Base):
+    __tablename__ = "replication_drift"
+
+    id = sa.Column(sa.Integer, primary_key=True)
+    job_id = sa.Column(sa.Integer, nullable=False)
+    policy_id = sa.Column(sa.Integer, nullable=False)
+    repository = sa.Column(sa.String(256), nullable=False)
+    tag = sa.Column(sa.String(128), nullable=False)
+    kind = sa.Column(sa.String(16), nullable=False)
+    src_digest = sa.Column(sa.String(128))
+    dst_digest = sa.Column(sa.String(128))
+    creation_time = sa.Column(mysql.TIMESTAMP, server_default = sa.text("CURRENT_TIMESTAMP"))
+
+    __table_args__ = (sa.Index('job', "job_id"),)
+
+class ReplicationExecution(Base):
+    __tablename__ = "replication_execution"
+
+    id = sa.Column(sa.Integer, primary_key=True)
+    policy_id = sa.Column(sa.Integer, nullable=False)
+    trigger_type = sa.Column(sa.String(64), nullable=False)
+    creation_time = sa.Column(mysql.TIMESTAMP, server_default = sa.text("CURRENT_TIMESTAMP"))
+
+    __table_args__ = (sa.Index('policy', "policy_id"),)
+
+class ReplicationJobResult(Base):
+    __tablename__ = "replication_job_result"
+
+    id = sa.Column(sa.Integer, primary_key=True)
+    job_id = sa.Column(sa.Integer, nullable=False)
+    repository = sa.Column(sa.String(255), nullable=False)
+    tag = sa.Column(sa.String(128), nullable=False)
+    result = sa.Column(sa.String(16), nullable=False)
+    src_digest = sa.Column(sa.String(128))
+    dst_digest = sa.Column(sa.String(128))
+    creation_time = sa.Column(mysql.TIMESTAMP, server_default = sa.text("CURRENT_TIMESTAMP"))
+
+    __table_args__ = (sa.UniqueConstraint('job_id', 'repository', 'tag'),)
+
+class ReplicationPlan(Base):
+    __tablename__ = "replication_plan"
+
+    id = sa.Column(sa.Integer, primary_key=True)
+    job_id = sa.Column(sa.Integer, nullable=False, unique=True)
+    policy_id = sa.Column(sa.Integer, nullable=False)
+    plan = sa.Column(mysql.MEDIUMTEXT, nullable=False)
+    creation_time = sa.Column(mysql.TIMESTAMP, server_default = sa.text("CURRENT_TIMESTAMP"))
+
+class ReplicationJobUpload(Base):
+    __tablename__ = "replication_job_upload"
+
+    id = sa.Column(sa.Integer, primary_key=True)
+    job_id = sa.Column(sa.Integer, nullable=False)
+    digest = sa.Column(sa.String(128), nullable=False)
+    location = sa.Column(sa.String(2048), nullable=False)
+    uuid = sa.Column(sa.String(64))
+    upload_offset = sa.Column(sa.BigInteger, nullable=False, server_default=sa.text("'0'"))
+    creation_time = sa.Column(mysql.TIMESTAMP, server_default = sa.text("CURRENT_TIMESTAMP"))
+
+    __table_args__ = (sa.UniqueConstraint('job_id', 'digest'),)
+
+class JobLease(Base):
+    __tablename__ = "job_lease"
+
+    name = sa.Column(sa.String(64), primary_key=True)
+    owner = sa.Column(sa.String(256), nullable=False)
+    expire_time = sa.Column(mysql.TIMESTAMP, nullable=True)
@@ -0,0 +1,111 @@
+# Copyright (c) 2008-2016 VMware, Inc. All Rights Reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""0.3.0 to 0.4.0
+
+Revision ID: 0.4.0
+Revises: 0.3.0
+
+"""
+
+# revision identifiers, used by Alembic.
+revision = '0.4.0'
+down_revision = '0.3.0'
+branch_labels = None
+depends_on = None
+
+from alembic import op
+from db_meta import *
+
+from sqlalchemy.dialects import mysql
+
+def upgrade():
+    """
+    update schema&data
+    """
+    bind = op.get_bind()
+    #add columns last_run_time and next_run_time to table replication_policy
+    op.add_column('replication_policy', sa.Column('last_run_time', mysql.TIMESTAMP, nullable=True))
+    op.add_column('replication_policy', sa.Column('next_run_time', mysql.TIMESTAMP, nullable=True))
+
+    #add columns priority, lease_owner and lease_expire_time and index queue to table replication_job
+    op.add_column('replication_job', sa.Column('priority', sa.Integer, nullable=False, server_default=sa.text("'0'")))
+    op.add_column('replication_job', sa.Column('lease_owner', sa.String(256)))
+    op.add_column('replication_job', sa.Column('lease_expire_time', mysql.TIMESTAMP, nullable=True))
+    op.create_index('queue', 'replication_job', ['status', 'priority', 'id'])
+
+    #add columns retry_count and next_retry_time to table replication_job
+    op.add_column('replication_job', sa.Column('retry_count', sa.Integer, nullable=False, server_default=sa.text("'0'")))
+    op.add_column('replication_job', sa.Column('next_retry_time', mysql.TIMESTAMP, nullable=True))
+
+    #add column progress to table replication_job
+    op.add_column('replication_job', sa.Column('progress', sa.String(1024)))
+
+    #add column filters to table replication_policy
+    op.add_column('replication_policy', sa.Column('filters', sa.Text))
+
+    #add column direction to table replication_policy
+    op.add_column('replication_policy', sa.Column('direction', sa.String(16), nullable=False, server_default=sa.text("'push'")))
+
+    #create table replication_drift
+    ReplicationDrift.__table__.create(bind)
+
+    #add columns bandwidth_limit and time_windows to table replication_target
+    op.add_column('replication_target', sa.Column('bandwidth_limit', sa.Integer, nullable=False, server_default=sa.text("'0'")))
+    op.add_column('replication_target', sa.Column('time_windows', sa.String(256)))
+
+    #add column stop_requested to table replication_job
+    op.add_column('replication_job', sa.Column('stop_requested', mysql.TINYINT(1), nullable=False, server_default=sa.text("'0'")))
+
+    #add column checkpoint to table replication_job
+    op.add_column('replication_job', sa.Column('checkpoint', sa.Text))
+
+    #add column parameters to table replication_job
+    op.add_column('replication_job', sa.Column('parameters', sa.Text))
+
+    #add column execution_id and index execution to table replication_job, create table replication_execution
+    op.add_column('replication_job', sa.Column('execution_id', sa.Integer, nullable=False, server_default=sa.text("'0'")))
+    op.create_index('execution', 'replication_job', ['execution_id'])
+    ReplicationExecution.__table__.create(bind)
+
+    #add column max_concurrent_jobs to table replication_policy and replication_target
+    op.add_column('replication_policy', sa.Column('max_concurrent_jobs', sa.Integer, nullable=False, server_default=sa.text("'0'")))
+    op.add_column('replication_target', sa.Column('max_concurrent_jobs', sa.Integer, nullable=False, server_default=sa.text("'0'")))
+
+    #add columns target_project and target_repo_prefix to table replication_policy
+    op.add_column('replication_policy', sa.Column('target_project', sa.String(256)))
+    op.add_column('replication_policy', sa.Column('target_repo_prefix', sa.String(256)))
+
+    #add columns mirror and mirror_threshold to table replication_policy
+    op.add_column('replication_policy', sa.Column('mirror', mysql.TINYINT(1), nullable=False, server_default=sa.text("'0'")))
+    op.add_column('replication_policy', sa.Column('mirror_threshold', sa.Integer, nullable=False, server_default=sa.text("'0'")))
+
+    #add column conflict_mode to table replication_policy, create table replication_job_result
+    op.add_column('replication_policy', sa.Column('conflict_mode', sa.String(16), nullable=False, server_default=sa.text("'overwrite'")))
+    ReplicationJobResult.__table__.create(bind)
+
+    #create table replication_job_upload
+    ReplicationJobUpload.__table__.create(bind)
+
+    #create table replication_plan
+    ReplicationPlan.__table__.create(bind)
+
+    #create table job_lease
+    JobLease.__table__.create(bind)
+
+def downgrade():
+    """
+    Downgrade has been disabled.
+    """
+    pass
@@ -23,6 +23,10 @@ func init() {
 	orm.RegisterModel(new(RepTarget),
 		new(RepPolicy),
 		new(RepJob),
+		new(RepDrift),
+		new(RepJobResult),
+		new(RepExecution),
+		new(RepBlobUpload),
 	        new(User),
 		new(Project),
 		new(Role),
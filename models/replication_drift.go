@@ -0,0 +1,53 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+import (
+	"time"
+)
+
+const (
+	//DriftMissing means the tag exists on the source but not on the destination.
+	DriftMissing string = "missing"
+	//DriftStale means the digest of the tag on the destination differs from the source.
+	DriftStale string = "stale"
+	//DriftExtra means the tag exists on the destination but not on the source.
+	DriftExtra string = "extra"
+)
+
+// RepDrift is a difference between the source and the destination of a policy found by a verification job
+type RepDrift struct {
+	ID           int64     `orm:"column(id)" json:"id"`
+	JobID        int64     `orm:"column(job_id)" json:"job_id"`
+	PolicyID     int64     `orm:"column(policy_id)" json:"policy_id"`
+	Repository   string    `orm:"column(repository)" json:"repository"`
+	Tag          string    `orm:"column(tag)" json:"tag"`
+	Kind         string    `orm:"column(kind)" json:"kind"`
+	SrcDigest    string    `orm:"column(src_digest)" json:"src_digest"`
+	DstDigest    string    `orm:"column(dst_digest)" json:"dst_digest"`
+	CreationTime time.Time `orm:"column(creation_time);auto_now_add" json:"creation_time"`
+}
+
+//TableName is required by by beego orm to map RepDrift to table replication_drift
+func (r *RepDrift) TableName() string {
+	return "replication_drift"
+}
+
+// RepDriftReport is the result of the last verification job of a policy
+type RepDriftReport struct {
+	Job    *RepJob     `json:"job"`
+	Drifts []*RepDrift `json:"drifts"`
+}
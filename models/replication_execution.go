@@ -0,0 +1,91 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+import (
+	"time"
+)
+
+const (
+	//RepTriggerManual means the execution is triggered by the user, e.g. enabling the policy.
+	RepTriggerManual string = "manual"
+	//RepTriggerEvent means the execution is triggered by pushing or deleting images.
+	RepTriggerEvent string = "event"
+	//RepTriggerSchedule means the execution is triggered by the cron schedule of the policy.
+	RepTriggerSchedule string = "schedule"
+)
+
+const (
+	//ExecutionInProgress means some jobs of the execution have not ended.
+	ExecutionInProgress string = "in_progress"
+	//ExecutionSucceed means all the jobs of the execution have finished.
+	ExecutionSucceed string = "succeed"
+	//ExecutionFailed means some jobs of the execution have failed.
+	ExecutionFailed string = "failed"
+	//ExecutionStopped means some jobs of the execution have been stopped or canceled and none has failed.
+	ExecutionStopped string = "stopped"
+)
+
+// RepExecution is a run of a policy, it groups the jobs created by one trigger. The status, counts
+// and end time are aggregated from the jobs and not stored in DB.
+type RepExecution struct {
+	ID           int64     `orm:"column(id)" json:"id"`
+	PolicyID     int64     `orm:"column(policy_id)" json:"policy_id"`
+	Trigger      string    `orm:"column(trigger_type)" json:"trigger"`
+	CreationTime time.Time `orm:"column(creation_time);auto_now_add" json:"start_time"`
+	EndTime      time.Time `orm:"-" json:"end_time"`
+	Status       string    `orm:"-" json:"status"`
+	Total        int       `orm:"-" json:"total"`
+	// the number of jobs in each status
+	StatusCounts map[string]int `orm:"-" json:"status_counts"`
+	Jobs         []*RepJob      `orm:"-" json:"jobs,omitempty"`
+}
+
+//TableName is required by by beego orm to map RepExecution to table replication_execution
+func (r *RepExecution) TableName() string {
+	return "replication_execution"
+}
+
+// Aggregate calculates the status, counts and end time of the execution from its jobs, the end
+// time is the last update time of the jobs and is left zero if the execution is in progress.
+func (r *RepExecution) Aggregate(jobs []*RepJob) {
+	r.Total = len(jobs)
+	r.StatusCounts = make(map[string]int)
+	r.EndTime = time.Time{}
+	var end time.Time
+	for _, j := range jobs {
+		r.StatusCounts[j.Status]++
+		if j.UpdateTime.After(end) {
+			end = j.UpdateTime
+		}
+	}
+
+	switch {
+	case r.StatusCounts[JobPending]+r.StatusCounts[JobRunning]+r.StatusCounts[JobRetrying] > 0:
+		r.Status = ExecutionInProgress
+		return
+	case r.StatusCounts[JobError] > 0:
+		r.Status = ExecutionFailed
+	case r.StatusCounts[JobStopped]+r.StatusCounts[JobCanceled] > 0:
+		r.Status = ExecutionStopped
+	default:
+		r.Status = ExecutionSucceed
+	}
+	if end.IsZero() {
+		end = r.CreationTime
+	}
+	r.EndTime = end
+}
@@ -0,0 +1,72 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregateExecution(t *testing.T) {
+	start := time.Date(2016, 6, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	cases := []struct {
+		statuses []string
+		status   string
+	}{
+		{nil, ExecutionSucceed},
+		{[]string{JobFinished, JobFinished}, ExecutionSucceed},
+		{[]string{JobFinished, JobRunning}, ExecutionInProgress},
+		{[]string{JobRetrying, JobError}, ExecutionInProgress},
+		{[]string{JobFinished, JobError, JobStopped}, ExecutionFailed},
+		{[]string{JobFinished, JobCanceled}, ExecutionStopped},
+	}
+
+	for _, c := range cases {
+		var jobs []*RepJob
+		for i, s := range c.statuses {
+			jobs = append(jobs, &RepJob{
+				Status:     s,
+				UpdateTime: end.Add(-time.Duration(i) * time.Minute),
+			})
+		}
+		e := &RepExecution{CreationTime: start}
+		e.Aggregate(jobs)
+		if e.Status != c.status {
+			t.Errorf("unexpected status for %v: %s, expected: %s", c.statuses, e.Status, c.status)
+		}
+		if e.Total != len(c.statuses) {
+			t.Errorf("unexpected total for %v: %d", c.statuses, e.Total)
+		}
+		var expectedEnd time.Time
+		switch {
+		case c.status == ExecutionInProgress:
+		case len(jobs) == 0:
+			expectedEnd = start
+		default:
+			expectedEnd = end
+		}
+		if !e.EndTime.Equal(expectedEnd) {
+			t.Errorf("unexpected end time for %v: %v, expected: %v", c.statuses, e.EndTime, expectedEnd)
+		}
+	}
+
+	e := &RepExecution{}
+	e.Aggregate([]*RepJob{{Status: JobFinished}, {Status: JobError}, {Status: JobFinished}})
+	if e.StatusCounts[JobFinished] != 2 || e.StatusCounts[JobError] != 1 {
+		t.Errorf("unexpected status counts: %v", e.StatusCounts)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	//FilterKindRepository means the filter is applied to the name of repository without the project name
+	FilterKindRepository string = "repository"
+	//FilterKindTag means the filter is applied to tags
+	FilterKindTag string = "tag"
+	//FilterTypeGlob means the pattern is a shell file name pattern, e.g. "release-*", in which
+	//"*" matches any sequence of characters including "/", "?" matches any single character
+	//and "[...]" matches a character in the class
+	FilterTypeGlob string = "glob"
+	//FilterTypeRegex means the pattern is a regular expression which must match the whole name
+	FilterTypeRegex string = "regex"
+)
+
+// RepFilter is a filter on repositories or tags of a replication policy. For a kind, a name is
+// replicated if it matches any of the include filters (or there is no include filter) and
+// matches none of the exclude filters.
+type RepFilter struct {
+	Kind    string `json:"kind"`
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"`
+	Exclude bool   `json:"exclude"`
+
+	// the pattern compiled by Validate
+	re *regexp.Regexp
+}
+
+// Validate checks the kind, type and pattern of the filter and compiles the pattern,
+// a filter must be validated before it's used to match names.
+func (f *RepFilter) Validate() error {
+	if f.Kind != FilterKindRepository && f.Kind != FilterKindTag {
+		return fmt.Errorf("invalid kind: %s", f.Kind)
+	}
+	if len(f.Pattern) == 0 {
+		return fmt.Errorf("pattern can not be empty")
+	}
+	switch f.Type {
+	case FilterTypeGlob:
+		expr, err := globToRegex(f.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid glob pattern %s: %v", f.Pattern, err)
+		}
+		if f.re, err = regexp.Compile("^(?:" + expr + ")$"); err != nil {
+			return fmt.Errorf("invalid glob pattern %s: %v", f.Pattern, err)
+		}
+	case FilterTypeRegex:
+		re, err := regexp.Compile("^(?:" + f.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regular expression %s: %v", f.Pattern, err)
+		}
+		f.re = re
+	default:
+		return fmt.Errorf("invalid type: %s", f.Type)
+	}
+	return nil
+}
+
+// globToRegex translates the glob pattern to a regular expression
+func globToRegex(pattern string) (string, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			buf.WriteString(pattern[i : i+end+2])
+			i += end + 1
+		case '\\':
+			if i == len(pattern)-1 {
+				return "", fmt.Errorf("trailing backslash")
+			}
+			i++
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	return buf.String(), nil
+}
+
+// Match returns whether the name matches the pattern of the filter, it returns false
+// if the filter has not been validated.
+func (f *RepFilter) Match(name string) bool {
+	return f.re != nil && f.re.MatchString(name)
+}
+
+// matchFilters applies the filters of the kind to the name
+func matchFilters(filters []*RepFilter, kind, name string) bool {
+	included, hasInclude := false, false
+	for _, f := range filters {
+		if f.Kind != kind {
+			continue
+		}
+		if f.Exclude {
+			if f.Match(name) {
+				return false
+			}
+			continue
+		}
+		hasInclude = true
+		if !included && f.Match(name) {
+			included = true
+		}
+	}
+	return !hasInclude || included
+}
+
+// MatchRepository returns whether the repository should be replicated according to the
+// filters of the policy, repository is the full name including the project name.
+func (r *RepPolicy) MatchRepository(repository string) bool {
+	name := strings.TrimRight(strings.TrimSpace(repository), "/")
+	if i := strings.Index(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return matchFilters(r.Filters, FilterKindRepository, name)
+}
+
+// MatchTag returns whether the tag should be replicated according to the filters of the policy
+func (r *RepPolicy) MatchTag(tag string) bool {
+	return matchFilters(r.Filters, FilterKindTag, tag)
+}
+
+// FilterTags returns the tags which should be replicated according to the filters of the policy
+func (r *RepPolicy) FilterTags(tags []string) []string {
+	var result []string
+	for _, tag := range tags {
+		if r.MatchTag(tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// HasTagFilter returns whether the policy has any filter on tags
+func (r *RepPolicy) HasTagFilter() bool {
+	for _, f := range r.Filters {
+		if f.Kind == FilterKindTag {
+			return true
+		}
+	}
+	return false
+}
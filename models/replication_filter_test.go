@@ -0,0 +1,121 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+import (
+	"testing"
+)
+
+func TestRepFilterValidate(t *testing.T) {
+	cases := []struct {
+		filter RepFilter
+		valid  bool
+	}{
+		{RepFilter{Kind: FilterKindTag, Type: FilterTypeGlob, Pattern: "release-*"}, true},
+		{RepFilter{Kind: FilterKindRepository, Type: FilterTypeRegex, Pattern: "app[0-9]+"}, true},
+		{RepFilter{Kind: "project", Type: FilterTypeGlob, Pattern: "*"}, false},
+		{RepFilter{Kind: FilterKindTag, Type: "prefix", Pattern: "v"}, false},
+		{RepFilter{Kind: FilterKindTag, Type: FilterTypeGlob, Pattern: ""}, false},
+		{RepFilter{Kind: FilterKindTag, Type: FilterTypeGlob, Pattern: "[a-"}, false},
+		{RepFilter{Kind: FilterKindTag, Type: FilterTypeRegex, Pattern: "(v"}, false},
+		{RepFilter{Kind: FilterKindTag, Type: FilterTypeGlob, Pattern: "v\\"}, false},
+		{RepFilter{Kind: FilterKindTag, Type: FilterTypeGlob, Pattern: "[z-a]"}, false},
+	}
+
+	for _, c := range cases {
+		err := c.filter.Validate()
+		if c.valid && err != nil {
+			t.Errorf("unexpected error for %+v: %v", c.filter, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("expected error for %+v, but got nil", c.filter)
+		}
+	}
+}
+
+func TestRepPolicyMatch(t *testing.T) {
+	policy := &RepPolicy{
+		Filters: []*RepFilter{
+			&RepFilter{Kind: FilterKindRepository, Type: FilterTypeGlob, Pattern: "app*"},
+			&RepFilter{Kind: FilterKindRepository, Type: FilterTypeRegex, Pattern: "app-(test|dev)", Exclude: true},
+			&RepFilter{Kind: FilterKindTag, Type: FilterTypeGlob, Pattern: "release-*"},
+			&RepFilter{Kind: FilterKindTag, Type: FilterTypeRegex, Pattern: "v[0-9]+"},
+		},
+	}
+	for _, f := range policy.Filters {
+		if err := f.Validate(); err != nil {
+			t.Fatalf("unexpected error for %+v: %v", f, err)
+		}
+	}
+
+	repos := map[string]bool{
+		"library/app":          true,
+		"library/app-prod":     true,
+		"library/app-test":     false,
+		"library/web":          false,
+		"library/myapp":        false,
+		"library/app/frontend": true,
+	}
+	for repo, expected := range repos {
+		if policy.MatchRepository(repo) != expected {
+			t.Errorf("unexpected result of matching repository %s, expected: %v", repo, expected)
+		}
+	}
+
+	tags := policy.FilterTags([]string{"latest", "release-1.0", "v1", "v1.0", "prerelease-1"})
+	if len(tags) != 2 || tags[0] != "release-1.0" || tags[1] != "v1" {
+		t.Errorf("unexpected filtered tags: %v", tags)
+	}
+
+	// no filter means everything matches
+	policy = &RepPolicy{}
+	if !policy.MatchRepository("library/web") || !policy.MatchTag("latest") {
+		t.Errorf("everything should match when there is no filter")
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"*", "team/app", true},
+		{"team/*", "team/app/frontend", true},
+		{"*/app", "team/app", true},
+		{"*/app", "app", false},
+		{"v?.*", "v1.0", true},
+		{"v?.*", "v10.0", false},
+		{"v[0-9].[!0-9]", "v1.a", false},
+		{"v[0-9].[^0-9]", "v1.a", true},
+		{"v[0-9].[^0-9]", "v1.0", false},
+		{"1.0+build", "1.0+build", true},
+		{"1.0+build", "1.00build", false},
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+	}
+
+	for _, c := range cases {
+		f := &RepFilter{Kind: FilterKindTag, Type: FilterTypeGlob, Pattern: c.pattern}
+		if err := f.Validate(); err != nil {
+			t.Errorf("unexpected error for %s: %v", c.pattern, err)
+			continue
+		}
+		if f.Match(c.name) != c.matched {
+			t.Errorf("unexpected result of matching %s with %s, expected: %v", c.name, c.pattern, c.matched)
+		}
+	}
+}
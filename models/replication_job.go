@@ -16,10 +16,13 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/astaxie/beego/validation"
 	"github.com/vmware/harbor/utils"
+	"github.com/vmware/harbor/utils/cron"
 )
 
 const (
@@ -43,8 +46,29 @@ const (
 	RepOpTransfer string = "transfer"
 	//RepOpDelete represents the operation of a job to remove repository from a remote registry/harbor instance.
 	RepOpDelete string = "delete"
+	//RepOpVerify represents the operation of a job to compare the tags of the source and the target of a policy.
+	RepOpVerify string = "verify"
+	//RepOpRepair represents the operation of a job to verify a policy and enqueue transfer jobs for the differences.
+	RepOpRepair string = "repair"
+	//RepOpPrune represents the operation of a job to verify a policy and enqueue delete jobs for the tags which only exist on the target.
+	RepOpPrune string = "prune"
+	//RepOpPlan represents the operation of a job to dry run a policy and record what the replication would do.
+	RepOpPlan string = "plan"
+	//JobOpCleanup represents the operation of a job to remove the ended jobs which are out of retention.
+	JobOpCleanup string = "cleanup"
 	//UISecretCookie is the cookie name to contain the UI secret
 	UISecretCookie string = "uisecret"
+	//RepDirectionPush means the policy pushes images from the local project to the target.
+	RepDirectionPush string = "push"
+	//RepDirectionPull means the policy pulls images from the target into the local project.
+	RepDirectionPull string = "pull"
+)
+
+const (
+	//RepTargetTypeHarbor means the target is a harbor instance, projects are created on it via harbor API.
+	RepTargetTypeHarbor int = 0
+	//RepTargetTypeRegistry means the target is a regular docker registry which only supports registry API.
+	RepTargetTypeRegistry int = 1
 )
 
 // RepPolicy is the model for a replication policy, which associate to a project and a target (destination)
@@ -55,14 +79,46 @@ type RepPolicy struct {
 	TargetID    int64  `orm:"column(target_id)" json:"target_id"`
 	TargetName  string `json:"target_name,omitempty"`
 	Name        string `orm:"column(name)" json:"name"`
+	Direction   string `orm:"column(direction)" json:"direction"`
 	//	Target       RepTarget `orm:"-" json:"target"`
-	Enabled       int       `orm:"column(enabled)" json:"enabled"`
-	Description   string    `orm:"column(description)" json:"description"`
-	CronStr       string    `orm:"column(cron_str)" json:"cron_str"`
-	StartTime     time.Time `orm:"column(start_time)" json:"start_time"`
-	CreationTime  time.Time `orm:"column(creation_time);auto_now_add" json:"creation_time"`
-	UpdateTime    time.Time `orm:"column(update_time);auto_now" json:"update_time"`
-	ErrorJobCount int       `json:"error_job_count"`
+	Enabled       int          `orm:"column(enabled)" json:"enabled"`
+	Description   string       `orm:"column(description)" json:"description"`
+	CronStr       string       `orm:"column(cron_str)" json:"cron_str"`
+	StartTime     time.Time    `orm:"column(start_time)" json:"start_time"`
+	LastRunTime   time.Time    `orm:"column(last_run_time)" json:"last_run_time"`
+	NextRunTime   time.Time    `orm:"column(next_run_time)" json:"next_run_time"`
+	FiltersStr    string       `orm:"column(filters)" json:"-"`
+	Filters       []*RepFilter `orm:"-" json:"filters"`
+	CreationTime  time.Time    `orm:"column(creation_time);auto_now_add" json:"creation_time"`
+	UpdateTime    time.Time    `orm:"column(update_time);auto_now" json:"update_time"`
+	ErrorJobCount int          `json:"error_job_count"`
+	// the max number of jobs of the policy running at the same time, 0 means unlimited
+	MaxConcurrentJobs int `orm:"column(max_concurrent_jobs)" json:"max_concurrent_jobs"`
+	// the project on the target which the repositories are replicated to(or from in pull mode),
+	// the project of the policy is used if it is empty
+	TargetProject string `orm:"column(target_project)" json:"target_project"`
+	// prepended to the names of the repositories on the target
+	TargetRepoPrefix string `orm:"column(target_repo_prefix)" json:"target_repo_prefix"`
+	// 1 means the tags which no longer exist at the source are pruned from the target in full syncs
+	Mirror int `orm:"column(mirror)" json:"mirror"`
+	// the max percentage of the tags on the target which can be pruned at once, the pruning
+	// is aborted if it's exceeded, 0 means DefaultMirrorThreshold
+	MirrorThreshold int `orm:"column(mirror_threshold)" json:"mirror_threshold"`
+	// how the tags which already exist on the target with different digests are handled:
+	// overwrite, skip or fail, the default is overwrite
+	ConflictMode string `orm:"column(conflict_mode)" json:"conflict_mode"`
+}
+
+// DefaultMirrorThreshold is the max percentage of the tags on the target which can be
+// pruned at once if the policy doesn't set one
+const DefaultMirrorThreshold = 50
+
+// PruneThreshold returns the max percentage of the tags on the target which can be pruned at once
+func (r *RepPolicy) PruneThreshold() int {
+	if r.MirrorThreshold <= 0 {
+		return DefaultMirrorThreshold
+	}
+	return r.MirrorThreshold
 }
 
 // Valid ...
@@ -87,9 +143,73 @@ func (r *RepPolicy) Valid(v *validation.Validation) {
 		v.SetError("enabled", "must be 0 or 1")
 	}
 
+	if len(r.Direction) == 0 {
+		r.Direction = RepDirectionPush
+	}
+
+	if r.Direction != RepDirectionPush && r.Direction != RepDirectionPull {
+		v.SetError("direction", "must be push or pull")
+	}
+
 	if len(r.CronStr) > 256 {
 		v.SetError("cron_str", "max length is 256")
 	}
+
+	if r.Mirror != 0 && r.Mirror != 1 {
+		v.SetError("mirror", "must be 0 or 1")
+	}
+
+	if r.Mirror == 1 && r.Direction == RepDirectionPull {
+		v.SetError("mirror", "is only supported by push policies")
+	}
+
+	if r.MirrorThreshold < 0 || r.MirrorThreshold > 100 {
+		v.SetError("mirror_threshold", "must be between 0 and 100")
+	}
+
+	if len(r.ConflictMode) == 0 {
+		r.ConflictMode = ConflictOverwrite
+	}
+
+	if r.ConflictMode != ConflictOverwrite && r.ConflictMode != ConflictSkip && r.ConflictMode != ConflictFail {
+		v.SetError("conflict_mode", "must be overwrite, skip or fail")
+	}
+
+	if r.MaxConcurrentJobs < 0 {
+		v.SetError("max_concurrent_jobs", "can not be negative")
+	}
+
+	if len(r.TargetProject) > 256 {
+		v.SetError("target_project", "max length is 256")
+	}
+
+	if strings.Contains(r.TargetProject, "/") {
+		v.SetError("target_project", "can not contain /")
+	}
+
+	if len(r.TargetRepoPrefix) > 256 {
+		v.SetError("target_repo_prefix", "max length is 256")
+	}
+
+	if strings.HasPrefix(r.TargetRepoPrefix, "/") || strings.Contains(r.TargetRepoPrefix, "//") {
+		v.SetError("target_repo_prefix", "invalid")
+	}
+
+	if len(r.CronStr) != 0 {
+		if _, err := cron.Parse(r.CronStr); err != nil {
+			v.SetError("cron_str", err.Error())
+		}
+	}
+
+	for _, f := range r.Filters {
+		if f == nil {
+			v.SetError("filters", "can not contain null")
+			continue
+		}
+		if err := f.Validate(); err != nil {
+			v.SetError("filters", err.Error())
+		}
+	}
 }
 
 // RepJob is the model for a replication job, which is the execution unit on job service, currently it is used to transfer/remove
@@ -102,21 +222,92 @@ type RepJob struct {
 	Operation  string   `orm:"column(operation)" json:"operation"`
 	Tags       string   `orm:"column(tags)" json:"-"`
 	TagList    []string `orm:"-" json:"tags"`
+	// the execution which the job belongs to, 0 if the job is not created by a trigger of the policy
+	ExecutionID int64 `orm:"column(execution_id)" json:"execution_id"`
+	// jobs with higher priority will be picked up from the queue first
+	Priority int `orm:"column(priority)" json:"priority"`
+	// the job service instance which claimed the job and when the claim expires
+	LeaseOwner      string    `orm:"column(lease_owner)" json:"-"`
+	LeaseExpireTime time.Time `orm:"column(lease_expire_time)" json:"-"`
+	// set when the job is requested to stop, the node owning the job will stop it
+	StopRequested int `orm:"column(stop_requested)" json:"-"`
+	// how many times the job has been retried and when it will be retried next time
+	RetryCount    int       `orm:"column(retry_count)" json:"retry_count"`
+	NextRetryTime time.Time `orm:"column(next_retry_time)" json:"next_retry_time"`
+	// the progress is stored as JSON in DB
+	ProgressStr string          `orm:"column(progress)" json:"-"`
+	Progress    *RepJobProgress `orm:"-" json:"progress,omitempty"`
+	// the tags skipped or conflicting because of the conflict mode of the policy
+	Results []*RepJobResult `orm:"-" json:"results,omitempty"`
+	// the tags which have not been replicated stored as a JSON list, the job is resumed from them if it
+	// is interrupted. NULL means there is no checkpoint, while "[]" means all the tags have been replicated.
+	Checkpoint string `orm:"column(checkpoint)" json:"-"`
+	// the parameters of the jobs which are not replication jobs, it's stored as JSON in DB
+	Parameters string `orm:"column(parameters)" json:"parameters,omitempty"`
 	//	Policy       RepPolicy `orm:"-" json:"policy"`
 	CreationTime time.Time `orm:"column(creation_time);auto_now_add" json:"creation_time"`
 	UpdateTime   time.Time `orm:"column(update_time);auto_now" json:"update_time"`
 }
 
+// RepJobProgress holds the progress of a running replication job, it is updated periodically by job service.
+type RepJobProgress struct {
+	TagsTotal  int    `json:"tags_total"`
+	TagsDone   int    `json:"tags_done"`
+	CurrentTag string `json:"current_tag"`
+	// blobs and bytes need to be transferred, they are added up from the manifest of each tag when
+	// the tag is reached, so they only cover the tags reached so far until TotalsKnown is true.
+	// The sizes of the layers of schema1 manifests are unknown, so BytesTotal doesn't include them.
+	BlobsTotal       int   `json:"blobs_total"`
+	BlobsDone        int   `json:"blobs_done"`
+	BytesTotal       int64 `json:"bytes_total"`
+	BytesTransferred int64 `json:"bytes_transferred"`
+	TotalsKnown      bool  `json:"totals_known"`
+	// average bytes transferred per second since the job started
+	Throughput int64     `json:"throughput"`
+	StartTime  time.Time `json:"start_time"`
+	UpdateTime time.Time `json:"update_time"`
+}
+
+// RepBlobUpload is an interrupted blob upload session of a job, it's resumed when the job is retried
+type RepBlobUpload struct {
+	ID       int64  `orm:"column(id)" json:"id"`
+	JobID    int64  `orm:"column(job_id)" json:"job_id"`
+	Digest   string `orm:"column(digest)" json:"digest"`
+	Location string `orm:"column(location)" json:"location"`
+	UUID     string `orm:"column(uuid)" json:"uuid"`
+	// the number of bytes the registry had received when the upload was interrupted
+	Offset       int64     `orm:"column(upload_offset)" json:"offset"`
+	CreationTime time.Time `orm:"column(creation_time);auto_now_add" json:"creation_time"`
+}
+
+//TableName is required by by beego orm to map RepBlobUpload to table replication_job_upload
+func (r *RepBlobUpload) TableName() string {
+	return "replication_job_upload"
+}
+
+// RepJobCleanup is the result of removing the jobs which are out of retention
+type RepJobCleanup struct {
+	JobIDs      []int64 `json:"job_ids"`
+	LogsRemoved int     `json:"logs_removed"`
+}
+
 // RepTarget is the model for a replication targe, i.e. destination, which wraps the endpoint URL and username/password of a remote registry.
 type RepTarget struct {
-	ID           int64     `orm:"column(id)" json:"id"`
-	URL          string    `orm:"column(url)" json:"endpoint"`
-	Name         string    `orm:"column(name)" json:"name"`
-	Username     string    `orm:"column(username)" json:"username"`
-	Password     string    `orm:"column(password)" json:"password"`
-	Type         int       `orm:"column(target_type)" json:"type"`
-	CreationTime time.Time `orm:"column(creation_time);auto_now_add" json:"creation_time"`
-	UpdateTime   time.Time `orm:"column(update_time);auto_now" json:"update_time"`
+	ID       int64  `orm:"column(id)" json:"id"`
+	URL      string `orm:"column(url)" json:"endpoint"`
+	Name     string `orm:"column(name)" json:"name"`
+	Username string `orm:"column(username)" json:"username"`
+	Password string `orm:"column(password)" json:"password"`
+	Type     int    `orm:"column(target_type)" json:"type"`
+	// KB per second, 0 means unlimited. The limit applies to each job service node separately,
+	// so the total bandwidth used by a cluster of N nodes can reach N times the limit.
+	BandwidthLimit int64 `orm:"column(bandwidth_limit)" json:"bandwidth_limit"`
+	// the periods of a day during which jobs for the target can run, e.g. "22:00-06:00,12:00-13:00"
+	TimeWindows string `orm:"column(time_windows)" json:"time_windows"`
+	// the max number of jobs for the target running at the same time, 0 means unlimited
+	MaxConcurrentJobs int       `orm:"column(max_concurrent_jobs)" json:"max_concurrent_jobs"`
+	CreationTime      time.Time `orm:"column(creation_time);auto_now_add" json:"creation_time"`
+	UpdateTime        time.Time `orm:"column(update_time);auto_now" json:"update_time"`
 }
 
 // Valid ...
@@ -133,6 +324,26 @@ func (r *RepTarget) Valid(v *validation.Validation) {
 		v.SetError("endpoint", "can not be empty")
 	}
 
+	if r.Type != RepTargetTypeHarbor && r.Type != RepTargetTypeRegistry {
+		v.SetError("type", "must be 0(harbor) or 1(registry)")
+	}
+
+	if r.BandwidthLimit < 0 {
+		v.SetError("bandwidth_limit", "can not be negative")
+	}
+
+	if r.MaxConcurrentJobs < 0 {
+		v.SetError("max_concurrent_jobs", "can not be negative")
+	}
+
+	if len(r.TimeWindows) > 256 {
+		v.SetError("time_windows", "max length is 256")
+	}
+
+	if _, err := parseTimeWindows(r.TimeWindows); err != nil {
+		v.SetError("time_windows", err.Error())
+	}
+
 	r.URL = utils.FormatEndpoint(r.URL)
 
 	if len(r.URL) > 64 {
@@ -151,6 +362,19 @@ func (r *RepTarget) TableName() string {
 	return "replication_target"
 }
 
+// RemainingTags returns the tags recorded by the checkpoint of the job, which may be empty if all
+// the tags have been replicated. The second return value is false if there is no checkpoint.
+func (r *RepJob) RemainingTags() ([]string, bool, error) {
+	if len(r.Checkpoint) == 0 {
+		return nil, false, nil
+	}
+	tags := []string{}
+	if err := json.Unmarshal([]byte(r.Checkpoint), &tags); err != nil {
+		return nil, false, err
+	}
+	return tags, true, nil
+}
+
 //TableName is required by by beego orm to map RepJob to table replication_job
 func (r *RepJob) TableName() string {
 	return "replication_job"
@@ -0,0 +1,62 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+import (
+	"strings"
+)
+
+// splitRepository splits the repository into the project name and the name
+// of the repository inside the project
+func splitRepository(repository string) (string, string) {
+	repository = strings.TrimRight(strings.TrimSpace(repository), "/")
+	i := strings.Index(repository, "/")
+	if i < 0 {
+		return "", repository
+	}
+	return repository[:i], repository[i+1:]
+}
+
+// RemoteProject returns the name of the project on the target which the local project
+// is mapped to, it is the same as the local one if the policy has no target project.
+func (r *RepPolicy) RemoteProject(project string) string {
+	if len(r.TargetProject) != 0 {
+		return r.TargetProject
+	}
+	return project
+}
+
+// RemoteRepository returns the name on the target of the local repository, repository
+// is the full name including the project name.
+func (r *RepPolicy) RemoteRepository(repository string) string {
+	project, name := splitRepository(repository)
+	return r.RemoteProject(project) + "/" + r.TargetRepoPrefix + name
+}
+
+// LocalRepository is the reverse of RemoteRepository, it returns the name of the repository
+// under the local project which the repository on the target is mapped to. The second return
+// value is false if the repository on the target is not covered by the policy.
+func (r *RepPolicy) LocalRepository(project, repository string) (string, bool) {
+	remoteProject, name := splitRepository(repository)
+	if remoteProject != r.RemoteProject(project) || !strings.HasPrefix(name, r.TargetRepoPrefix) {
+		return "", false
+	}
+	name = name[len(r.TargetRepoPrefix):]
+	if len(name) == 0 {
+		return "", false
+	}
+	return project + "/" + name, true
+}
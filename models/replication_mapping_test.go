@@ -0,0 +1,67 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+import (
+	"testing"
+)
+
+func TestRemoteRepository(t *testing.T) {
+	cases := []struct {
+		policy   *RepPolicy
+		local    string
+		expected string
+	}{
+		{&RepPolicy{}, "library/ubuntu", "library/ubuntu"},
+		{&RepPolicy{TargetProject: "mirror"}, "library/ubuntu", "mirror/ubuntu"},
+		{&RepPolicy{TargetRepoPrefix: "dev-"}, "library/ubuntu", "library/dev-ubuntu"},
+		{&RepPolicy{TargetProject: "mirror", TargetRepoPrefix: "library/"}, "library/ubuntu", "mirror/library/ubuntu"},
+	}
+
+	for _, c := range cases {
+		remote := c.policy.RemoteRepository(c.local)
+		if remote != c.expected {
+			t.Errorf("unexpected remote repository of %s: %s != %s", c.local, remote, c.expected)
+			continue
+		}
+
+		local, ok := c.policy.LocalRepository("library", remote)
+		if !ok || local != c.local {
+			t.Errorf("unexpected local repository of %s: %s, %v", remote, local, ok)
+		}
+	}
+}
+
+func TestLocalRepository(t *testing.T) {
+	policy := &RepPolicy{TargetProject: "mirror", TargetRepoPrefix: "dev-"}
+	cases := []struct {
+		remote   string
+		expected string
+		ok       bool
+	}{
+		{"mirror/dev-ubuntu", "library/ubuntu", true},
+		{"mirror/ubuntu", "", false},
+		{"library/dev-ubuntu", "", false},
+		{"mirror/dev-", "", false},
+	}
+
+	for _, c := range cases {
+		local, ok := policy.LocalRepository("library", c.remote)
+		if ok != c.ok || local != c.expected {
+			t.Errorf("unexpected local repository of %s: %s, %v != %s, %v", c.remote, local, ok, c.expected, c.ok)
+		}
+	}
+}
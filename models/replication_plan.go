@@ -0,0 +1,83 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+// RepPlan is the result of a dry run of a replication policy, it describes what
+// the replication would do without pushing anything.
+type RepPlan struct {
+	PolicyID         int64                `json:"policy_id"`
+	Direction        string               `json:"direction"`
+	ProjectsToCreate []string             `json:"projects_to_create"`
+	Repositories     []*RepPlanRepository `json:"repositories"`
+	TagsToTransfer   int                  `json:"tags_to_transfer"`
+	TagsUpToDate     int                  `json:"tags_up_to_date"`
+	// the tags existing on the destination with different digests, which are skipped or
+	// fail the jobs according to the conflict mode of the policy
+	TagsSkipped     int   `json:"tags_skipped"`
+	TagsConflicting int   `json:"tags_conflicting"`
+	BlobsMissing    int   `json:"blobs_missing"`
+	BytesMissing    int64 `json:"bytes_missing"`
+	// the tags which would be pruned from the target, it's only set for mirror-mode policies
+	Prune *RepPlanPrune `json:"prune,omitempty"`
+}
+
+// RepPlanReport is the result of the last plan job of a policy, Plan is nil if the job
+// has not finished.
+type RepPlanReport struct {
+	Job  *RepJob  `json:"job"`
+	Plan *RepPlan `json:"plan"`
+}
+
+// RepPlanPrune describes the tags which only exist on the target of a mirror-mode policy
+type RepPlanPrune struct {
+	Repositories []*RepPlanPruneRepository `json:"repositories"`
+	TagsToPrune  int                       `json:"tags_to_prune"`
+	// the number of the tags on the target
+	TagsTotal int `json:"tags_total"`
+	// nothing would be pruned as the tags to prune exceed the threshold of the policy
+	Aborted bool `json:"aborted"`
+}
+
+// RepPlanPruneRepository holds the tags of a repository which would be pruned
+type RepPlanPruneRepository struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// RepPlanRepository holds the plan of a repository
+type RepPlanRepository struct {
+	Name string        `json:"name"`
+	Tags []*RepPlanTag `json:"tags"`
+}
+
+// RepPlanTag holds the plan of a tag. If UpToDate is true, the manifest on the
+// destination registry is the same as the source one and nothing will be transferred.
+// Conflict is TagResultSkipped or TagResultConflict if the tag exists on the destination
+// with a different digest and the conflict mode of the policy doesn't overwrite it.
+type RepPlanTag struct {
+	Tag          string         `json:"tag"`
+	Digest       string         `json:"digest"`
+	UpToDate     bool           `json:"up_to_date"`
+	Conflict     string         `json:"conflict,omitempty"`
+	MissingBlobs []*RepPlanBlob `json:"missing_blobs"`
+}
+
+// RepPlanBlob is a blob missing on the destination registry. Size is 0 if it is
+// unknown, e.g. the blob is referenced by a schema1 manifest.
+type RepPlanBlob struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
@@ -0,0 +1,53 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+import (
+	"time"
+)
+
+const (
+	//ConflictOverwrite means the tags on the target are overwritten if their digests differ from the source.
+	ConflictOverwrite string = "overwrite"
+	//ConflictSkip means the tags which already exist on the target are kept and skipped.
+	ConflictSkip string = "skip"
+	//ConflictFail means the job fails if a tag exists on the target with a different digest.
+	ConflictFail string = "fail"
+)
+
+const (
+	//TagResultSkipped means the tag was not replicated as it already exists on the target.
+	TagResultSkipped string = "skipped"
+	//TagResultConflict means the tag exists on the target with a different digest, which failed the job.
+	TagResultConflict string = "conflict"
+)
+
+// RepJobResult records a tag which was not replicated by a transfer job because of the conflict mode of the policy
+type RepJobResult struct {
+	ID           int64     `orm:"column(id)" json:"id"`
+	JobID        int64     `orm:"column(job_id)" json:"job_id"`
+	Repository   string    `orm:"column(repository)" json:"repository"`
+	Tag          string    `orm:"column(tag)" json:"tag"`
+	Result       string    `orm:"column(result)" json:"result"`
+	SrcDigest    string    `orm:"column(src_digest)" json:"src_digest"`
+	DstDigest    string    `orm:"column(dst_digest)" json:"dst_digest"`
+	CreationTime time.Time `orm:"column(creation_time);auto_now_add" json:"creation_time"`
+}
+
+//TableName is required by by beego orm to map RepJobResult to table replication_job_result
+func (r *RepJobResult) TableName() string {
+	return "replication_job_result"
+}
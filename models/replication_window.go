@@ -0,0 +1,91 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeWindow is a period of a day, in minutes since midnight. A window whose
+// start is later than its end spans midnight, e.g. 22:00-06:00.
+type timeWindow struct {
+	start int
+	end   int
+}
+
+func (w timeWindow) contains(minute int) bool {
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// parseTimeWindows parses windows in the format of "HH:MM-HH:MM", separated by comma
+func parseTimeWindows(str string) ([]timeWindow, error) {
+	var windows []timeWindow
+	for _, s := range strings.Split(str, ",") {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+		parts := strings.Split(s, "-")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid time window %s, should be in the format of HH:MM-HH:MM", s)
+		}
+		start, err := parseClock(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if start == end {
+			return nil, fmt.Errorf("invalid time window %s, start and end are the same", s)
+		}
+		windows = append(windows, timeWindow{start: start, end: end})
+	}
+	return windows, nil
+}
+
+// parseClock converts "HH:MM" to minutes since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s, should be in the format of HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// InTimeWindow returns true if jobs for the target are allowed to run at the time t,
+// which is always true if the target has no time window. The windows are in the
+// local time zone of t.
+func (r *RepTarget) InTimeWindow(t time.Time) bool {
+	windows, err := parseTimeWindows(r.TimeWindows)
+	if err != nil || len(windows) == 0 {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range windows {
+		if w.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
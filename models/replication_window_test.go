@@ -0,0 +1,74 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeWindows(t *testing.T) {
+	cases := []struct {
+		str   string
+		valid bool
+	}{
+		{"", true},
+		{"22:00-06:00", true},
+		{"01:00-05:30, 12:00-13:00", true},
+		{"22:00", false},
+		{"22:00-25:00", false},
+		{"10:00-10:00", false},
+		{"a-b", false},
+	}
+
+	for _, c := range cases {
+		_, err := parseTimeWindows(c.str)
+		if c.valid && err != nil {
+			t.Errorf("unexpected error for %q: %v", c.str, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("expected error for %q, but got nil", c.str)
+		}
+	}
+}
+
+func TestInTimeWindow(t *testing.T) {
+	target := &RepTarget{
+		TimeWindows: "22:00-06:00,12:00-13:00",
+	}
+
+	cases := map[string]bool{
+		"23:30": true,
+		"00:00": true,
+		"05:59": true,
+		"06:00": false,
+		"12:30": true,
+		"13:00": false,
+		"18:00": false,
+	}
+	for clock, expected := range cases {
+		tm, _ := time.Parse("15:04", clock)
+		if target.InTimeWindow(tm) != expected {
+			t.Errorf("unexpected result for %s, expected: %v", clock, expected)
+		}
+	}
+
+	// no window means jobs can run at any time
+	target = &RepTarget{}
+	if !target.InTimeWindow(time.Now()) {
+		t.Errorf("jobs should be allowed to run at any time when there is no window")
+	}
+}
@@ -34,7 +34,8 @@ type NotificationHandler struct {
 	beego.Controller
 }
 
-const manifestPattern = `^application/vnd.docker.distribution.manifest.v\d\+json`
+// matches docker manifests, docker manifest lists, OCI image manifests and OCI image indexes
+const manifestPattern = `^application/vnd\.(docker\.distribution\.manifest\.(list\.)?v\d|oci\.image\.(manifest|index)\.v\d)\+json`
 
 // Post handles POST request, and records audit log or refreshes cache based on event.
 func (n *NotificationHandler) Post() {
@@ -107,6 +108,12 @@ func filterEvents(notification *models.Notification) ([]*models.Event, error) {
 			continue
 		}
 
+		// the manifests referenced by a manifest list or an OCI index are pushed by digest before
+		// the index, only the push of the tagged index is collected
+		if event.Action == "push" && len(event.Target.Tag) == 0 {
+			continue
+		}
+
 		//pull and push manifest by docker-client
 		if strings.HasPrefix(event.Request.UserAgent, "docker") && (event.Action == "pull" || event.Action == "push") {
 			events = append(events, &event)
@@ -71,10 +71,17 @@ func initRouters() {
 	beego.Router("/api/jobs/replication/", &api.RepJobAPI{}, "get:List")
 	beego.Router("/api/jobs/replication/:id([0-9]+)", &api.RepJobAPI{})
 	beego.Router("/api/jobs/replication/:id([0-9]+)/log", &api.RepJobAPI{}, "get:GetLog")
+	beego.Router("/api/jobs/replication/:id([0-9]+)/actions", &api.RepJobAPI{}, "post:Action")
+	beego.Router("/api/jobs/replication/cleanup", &api.RepJobAPI{}, "post:Cleanup")
 	beego.Router("/api/policies/replication/:id([0-9]+)", &api.RepPolicyAPI{})
 	beego.Router("/api/policies/replication", &api.RepPolicyAPI{}, "get:List")
 	beego.Router("/api/policies/replication", &api.RepPolicyAPI{}, "post:Post")
 	beego.Router("/api/policies/replication/:id([0-9]+)/enablement", &api.RepPolicyAPI{}, "put:UpdateEnablement")
+	beego.Router("/api/policies/replication/:id([0-9]+)/plan", &api.RepPolicyAPI{}, "post:Plan;get:GetPlan")
+	beego.Router("/api/policies/replication/:id([0-9]+)/verification", &api.RepPolicyAPI{}, "post:Verify")
+	beego.Router("/api/policies/replication/:id([0-9]+)/drift", &api.RepPolicyAPI{}, "get:GetDrift")
+	beego.Router("/api/policies/replication/:id([0-9]+)/executions", &api.RepPolicyAPI{}, "get:ListExecutions")
+	beego.Router("/api/policies/replication/:id([0-9]+)/executions/:eid([0-9]+)", &api.RepPolicyAPI{}, "get:GetExecution")
 	beego.Router("/api/targets/", &api.TargetAPI{}, "get:List")
 	beego.Router("/api/targets/", &api.TargetAPI{}, "post:Post")
 	beego.Router("/api/targets/:id([0-9]+)", &api.TargetAPI{})
@@ -0,0 +1,77 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 7",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every",
+		"0 0 31 2 *",
+		"0 0 30,31 2 *",
+	}
+
+	for _, spec := range specs {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected error when parsing %q, but got nil", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	base := time.Date(2016, time.August, 15, 10, 30, 20, 0, time.UTC) // Monday
+
+	cases := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2016, time.August, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2016, time.August, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2016, time.August, 16, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2016, time.August, 16, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2016, time.August, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 1 * * 0", time.Date(2016, time.August, 21, 1, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2016, time.September, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,20 * 3", time.Date(2016, time.August, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2016, time.August, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", c.spec, err)
+			continue
+		}
+		if next := s.Next(base); !next.Equal(c.expected) {
+			t.Errorf("unexpected next time of %q: %v, expected: %v", c.spec, next, c.expected)
+		}
+	}
+}
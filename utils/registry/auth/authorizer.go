@@ -32,6 +32,30 @@ type Authorizer interface {
 	Authorize(req *http.Request, params map[string]string) error
 }
 
+// basicAuthorizer authorizes the request with basic auth, it is used when the registry
+// challenges with "Basic" scheme
+type basicAuthorizer struct {
+	credential Credential
+}
+
+// NewBasicAuthorizer returns an authorizer which adds the credential to the request directly
+func NewBasicAuthorizer(credential Credential) Authorizer {
+	return &basicAuthorizer{
+		credential: credential,
+	}
+}
+
+// Scheme returns the scheme that the handler can handle
+func (b *basicAuthorizer) Scheme() string {
+	return "basic"
+}
+
+// Authorize adds the credential to the request
+func (b *basicAuthorizer) Authorize(req *http.Request, params map[string]string) error {
+	b.credential.AddAuthorization(req)
+	return nil
+}
+
 // AuthorizerStore holds a authorizer list, which will authorize request.
 // And it implements interface Modifier
 type AuthorizerStore struct {
@@ -16,10 +16,112 @@
 package registry
 
 import (
+	"encoding/json"
+
 	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+const (
+	// MediaTypeOCIManifest is the media type of OCI image manifest
+	MediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	// MediaTypeOCIIndex is the media type of OCI image index, which references the manifests
+	// of an image for multiple platforms like the docker manifest list
+	MediaTypeOCIIndex = "application/vnd.oci.image.index.v1+json"
 )
 
-// UnMarshal converts []byte to be distribution.Manifest
+// ManifestMediaTypes are the media types of manifests accepted when pulling manifests
+var ManifestMediaTypes = []string{
+	schema1.MediaTypeManifest,
+	schema2.MediaTypeManifest,
+	MediaTypeOCIManifest,
+	manifestlist.MediaTypeManifestList,
+	MediaTypeOCIIndex,
+}
+
+// ociManifest is an OCI image manifest. The OCI manifest and index are handled here by media
+// type as the version of distribution in use doesn't support them, the payload is kept as is
+// so that the digest doesn't change when the manifest is pushed.
+type ociManifest struct {
+	Config    distribution.Descriptor   `json:"config"`
+	Layers    []distribution.Descriptor `json:"layers"`
+	canonical []byte
+}
+
+// References returns the layers of the manifest, the config is returned by ConfigOf
+func (m *ociManifest) References() []distribution.Descriptor {
+	return m.Layers
+}
+
+// Payload ...
+func (m *ociManifest) Payload() (string, []byte, error) {
+	return MediaTypeOCIManifest, m.canonical, nil
+}
+
+// ociIndex is an OCI image index
+type ociIndex struct {
+	Manifests []distribution.Descriptor `json:"manifests"`
+	canonical []byte
+}
+
+// References returns the manifests referenced by the index
+func (m *ociIndex) References() []distribution.Descriptor {
+	return m.Manifests
+}
+
+// Payload ...
+func (m *ociIndex) Payload() (string, []byte, error) {
+	return MediaTypeOCIIndex, m.canonical, nil
+}
+
+// UnMarshal converts []byte to be distribution.Manifest. For OCI manifests and indexes, the digest
+// of the descriptor returned is empty, it is got from the registry together with the payload.
 func UnMarshal(mediaType string, data []byte) (distribution.Manifest, distribution.Descriptor, error) {
-	return distribution.UnmarshalManifest(mediaType, data)
+	var manifest distribution.Manifest
+	switch mediaType {
+	case MediaTypeOCIManifest:
+		m := &ociManifest{}
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+		m.canonical = data
+		manifest = m
+	case MediaTypeOCIIndex:
+		m := &ociIndex{}
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+		m.canonical = data
+		manifest = m
+	default:
+		return distribution.UnmarshalManifest(mediaType, data)
+	}
+
+	return manifest, distribution.Descriptor{
+		MediaType: mediaType,
+		Size:      int64(len(data)),
+	}, nil
+}
+
+// IsIndex returns whether the manifest is a docker manifest list or an OCI image index,
+// whose references are manifests rather than blobs
+func IsIndex(manifest distribution.Manifest) bool {
+	switch manifest.(type) {
+	case *manifestlist.DeserializedManifestList, *ociIndex:
+		return true
+	}
+	return false
+}
+
+// ConfigOf returns the config of the manifest if it is a docker schema2 or an OCI manifest
+func ConfigOf(manifest distribution.Manifest) (distribution.Descriptor, bool) {
+	switch m := manifest.(type) {
+	case *schema2.DeserializedManifest:
+		return m.Config, true
+	case *ociManifest:
+		return m.Config, true
+	}
+	return distribution.Descriptor{}, false
 }
@@ -0,0 +1,96 @@
+/*
+   Copyright (c) 2016 VMware, Inc. All Rights Reserved.
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package registry
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+func TestUnMarshalIndex(t *testing.T) {
+	cases := []struct {
+		mediaType string
+		payload   string
+		isIndex   bool
+		hasConfig bool
+		refs      int
+	}{
+		{
+			mediaType: schema2.MediaTypeManifest,
+			payload: `{"schemaVersion": 2, "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+				"config": {"mediaType": "application/vnd.docker.container.image.v1+json", "size": 7023,
+				"digest": "sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"},
+				"layers": [{"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "size": 32654,
+				"digest": "sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f"}]}`,
+			isIndex:   false,
+			hasConfig: true,
+			refs:      1,
+		},
+		{
+			mediaType: MediaTypeOCIManifest,
+			payload: `{"schemaVersion": 2, "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "size": 7023,
+				"digest": "sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"},
+				"layers": [{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "size": 32654,
+				"digest": "sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f"}]}`,
+			isIndex:   false,
+			hasConfig: true,
+			refs:      1,
+		},
+		{
+			mediaType: manifestlist.MediaTypeManifestList,
+			payload: `{"schemaVersion": 2, "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+				"manifests": [{"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "size": 7143,
+				"digest": "sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f",
+				"platform": {"architecture": "ppc64le", "os": "linux"}},
+				{"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "size": 7682,
+				"digest": "sha256:5b0bcabd1ed22e9fb1310cf6c2dec7cdef19f0ad69efa1f392e94a4333501270",
+				"platform": {"architecture": "amd64", "os": "linux"}}]}`,
+			isIndex: true,
+			refs:    2,
+		},
+		{
+			mediaType: MediaTypeOCIIndex,
+			payload: `{"schemaVersion": 2, "manifests": [{"mediaType": "application/vnd.oci.image.manifest.v1+json",
+				"size": 7143, "digest": "sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f",
+				"platform": {"architecture": "arm64", "os": "linux"}}]}`,
+			isIndex: true,
+			refs:    1,
+		},
+	}
+
+	for _, c := range cases {
+		manifest, _, err := UnMarshal(c.mediaType, []byte(c.payload))
+		if err != nil {
+			t.Errorf("failed to unmarshal manifest of %s: %v", c.mediaType, err)
+			continue
+		}
+		if IsIndex(manifest) != c.isIndex {
+			t.Errorf("unexpected result of IsIndex for %s: %v", c.mediaType, !c.isIndex)
+		}
+		if len(manifest.References()) < c.refs {
+			t.Errorf("unexpected references of %s: %v", c.mediaType, manifest.References())
+		}
+		if _, ok := ConfigOf(manifest); ok != c.hasConfig {
+			t.Errorf("unexpected result of ConfigOf for %s: %v", c.mediaType, ok)
+		}
+		mediaType, payload, err := manifest.Payload()
+		if err != nil || mediaType != c.mediaType || string(payload) != c.payload {
+			t.Errorf("unexpected payload of %s: %s, %v", c.mediaType, mediaType, err)
+		}
+	}
+}
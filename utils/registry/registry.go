@@ -14,7 +14,6 @@
 package registry
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -53,13 +52,7 @@ func NewRegistryWithModifiers(endpoint string, insecure bool, modifiers ...Modif
 		return nil, err
 	}
 
-	t := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: insecure,
-		},
-	}
-
-	transport := NewTransport(t, modifiers...)
+	transport := NewTransport(GetHTTPTransport(insecure), modifiers...)
 
 	return &Registry{
 		Endpoint: u,
@@ -17,7 +17,6 @@ package registry
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -27,10 +26,8 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/docker/distribution/manifest/schema1"
-	"github.com/docker/distribution/manifest/schema2"
-
 	"github.com/vmware/harbor/utils"
+	"github.com/vmware/harbor/utils/log"
 	registry_error "github.com/vmware/harbor/utils/registry/error"
 )
 
@@ -68,13 +65,7 @@ func NewRepositoryWithModifiers(name, endpoint string, insecure bool, modifiers
 		return nil, err
 	}
 
-	t := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: insecure,
-		},
-	}
-
-	transport := NewTransport(t, modifiers...)
+	transport := NewTransport(GetHTTPTransport(insecure), modifiers...)
 
 	return &Repository{
 		Name:     name,
@@ -141,8 +132,9 @@ func (r *Repository) ManifestExist(reference string) (digest string, exist bool,
 		return
 	}
 
-	req.Header.Add(http.CanonicalHeaderKey("Accept"), schema1.MediaTypeManifest)
-	req.Header.Add(http.CanonicalHeaderKey("Accept"), schema2.MediaTypeManifest)
+	for _, mediaType := range ManifestMediaTypes {
+		req.Header.Add(http.CanonicalHeaderKey("Accept"), mediaType)
+	}
 
 	resp, err := r.client.Do(req)
 	if err != nil {
@@ -327,10 +319,19 @@ func (r *Repository) BlobExist(digest string) (bool, error) {
 
 // PullBlob : client must close data if it is not nil
 func (r *Repository) PullBlob(digest string) (size int64, data io.ReadCloser, err error) {
+	return r.PullBlobFrom(digest, 0)
+}
+
+// PullBlobFrom pulls the content of blob from the offset, the size returned is the number of
+// bytes remaining in data. Client must close data if it is not nil
+func (r *Repository) PullBlobFrom(digest string, offset int64) (size int64, data io.ReadCloser, err error) {
 	req, err := http.NewRequest("GET", buildBlobURL(r.Endpoint.String(), r.Name, digest), nil)
 	if err != nil {
 		return
 	}
+	if offset > 0 {
+		req.Header.Set(http.CanonicalHeaderKey("Range"), fmt.Sprintf("bytes=%d-", offset))
+	}
 
 	resp, err := r.client.Do(req)
 	if err != nil {
@@ -338,12 +339,21 @@ func (r *Repository) PullBlob(digest string) (size int64, data io.ReadCloser, er
 		return
 	}
 
-	if resp.StatusCode == http.StatusOK {
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent {
 		contengLength := resp.Header.Get(http.CanonicalHeaderKey("Content-Length"))
 		size, err = strconv.ParseInt(contengLength, 10, 64)
 		if err != nil {
+			resp.Body.Close()
 			return
 		}
+		// the range is not supported by the registry, skip the bytes before offset
+		if offset > 0 && resp.StatusCode == http.StatusOK {
+			if _, err = io.CopyN(ioutil.Discard, resp.Body, offset); err != nil {
+				resp.Body.Close()
+				return
+			}
+			size -= offset
+		}
 		data = resp.Body
 		return
 	}
@@ -432,6 +442,239 @@ func (r *Repository) PushBlob(digest string, size int64, data io.Reader) error {
 	return r.monolithicBlobUpload(location, digest, size, data)
 }
 
+// MountBlob tries to mount the blob from another repository in the same registry, which avoids
+// uploading the blob again. It returns false if the blob can not be mounted, e.g. the blob does
+// not exist in the repository from or the user has no pull privilege to it.
+func (r *Repository) MountBlob(digest, from string) (bool, error) {
+	req, err := http.NewRequest("POST", buildMountBlobURL(r.Endpoint.String(), r.Name, digest, from), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set(http.CanonicalHeaderKey("Content-Length"), "0")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return false, parseError(err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusCreated {
+		return true, nil
+	}
+
+	// the registry falls back to a normal upload, cancel it as it will not be used
+	if resp.StatusCode == http.StatusAccepted {
+		if location := resp.Header.Get(http.CanonicalHeaderKey("Location")); len(location) != 0 {
+			if err := r.CancelBlobUpload(&BlobUpload{Location: r.resolveLocation(location)}); err != nil {
+				log.Warningf("failed to cancel the upload session %s: %v", location, err)
+			}
+		}
+		return false, nil
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+
+	return false, &registry_error.Error{
+		StatusCode: resp.StatusCode,
+		Detail:     string(b),
+	}
+}
+
+// CancelBlobUpload cancels the upload session
+func (r *Repository) CancelBlobUpload(upload *BlobUpload) error {
+	req, err := http.NewRequest("DELETE", upload.Location, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return parseError(err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return &registry_error.Error{
+		StatusCode: resp.StatusCode,
+		Detail:     string(b),
+	}
+}
+
+// BlobUpload holds the state of a blob upload session. The location changes after every
+// chunk is uploaded, so the latest one must be used to continue the upload.
+type BlobUpload struct {
+	Location string
+	UUID     string
+	// the number of bytes the registry has received
+	Offset int64
+}
+
+// InitiateBlobUpload starts a new upload session
+func (r *Repository) InitiateBlobUpload() (*BlobUpload, error) {
+	location, uploadUUID, err := r.initiateBlobUpload(r.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlobUpload{
+		Location: r.resolveLocation(location),
+		UUID:     uploadUUID,
+	}, nil
+}
+
+// ResumeBlobUpload gets the status of an existing upload session by its location or UUID,
+// the returned BlobUpload contains the offset from which the upload should be continued.
+// An error with status code 404 is returned if the session does not exist any more.
+func (r *Repository) ResumeBlobUpload(locationOrUUID string) (*BlobUpload, error) {
+	location := locationOrUUID
+	if !strings.Contains(location, "/") {
+		location = buildBlobUploadURL(r.Endpoint.String(), r.Name, locationOrUUID)
+	}
+	location = r.resolveLocation(location)
+
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, parseError(err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return r.parseBlobUpload(resp, location)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, &registry_error.Error{
+		StatusCode: resp.StatusCode,
+		Detail:     string(b),
+	}
+}
+
+// PushBlobChunk uploads size bytes read from data as a chunk of the upload session,
+// the location and offset of the upload are updated if it succeeds.
+func (r *Repository) PushBlobChunk(upload *BlobUpload, size int64, data io.Reader) error {
+	req, err := http.NewRequest("PATCH", upload.Location, io.LimitReader(data, size))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set(http.CanonicalHeaderKey("Content-Type"), "application/octet-stream")
+	req.Header.Set(http.CanonicalHeaderKey("Content-Range"),
+		fmt.Sprintf("%d-%d", upload.Offset, upload.Offset+size-1))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return parseError(err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusAccepted {
+		u, err := r.parseBlobUpload(resp, upload.Location)
+		if err != nil {
+			return err
+		}
+		*upload = *u
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return &registry_error.Error{
+		StatusCode: resp.StatusCode,
+		Detail:     string(b),
+	}
+}
+
+// CompleteBlobUpload finishes the upload session after all the chunks are uploaded
+func (r *Repository) CompleteBlobUpload(upload *BlobUpload, digest string) error {
+	return r.monolithicBlobUpload(upload.Location, digest, 0, nil)
+}
+
+// PushBlobChunked uploads the data from the offset of the upload session in chunks and completes
+// the session. size is the number of bytes remaining in data. If an error occurs, the upload can
+// be continued later from the offset which the registry has received.
+func (r *Repository) PushBlobChunked(upload *BlobUpload, digest string, size, chunkSize int64, data io.Reader) error {
+	if chunkSize <= 0 {
+		chunkSize = size
+	}
+
+	for size > 0 {
+		n := chunkSize
+		if n > size {
+			n = size
+		}
+		if err := r.PushBlobChunk(upload, n, data); err != nil {
+			return err
+		}
+		size -= n
+	}
+
+	return r.CompleteBlobUpload(upload, digest)
+}
+
+// parseBlobUpload reads the location, UUID and range from the headers of response
+func (r *Repository) parseBlobUpload(resp *http.Response, location string) (*BlobUpload, error) {
+	upload := &BlobUpload{
+		Location: location,
+		UUID:     resp.Header.Get(http.CanonicalHeaderKey("Docker-Upload-UUID")),
+	}
+	if l := resp.Header.Get(http.CanonicalHeaderKey("Location")); len(l) != 0 {
+		upload.Location = r.resolveLocation(l)
+	}
+
+	// the format of range is "0-<offset-1>"
+	rng := resp.Header.Get(http.CanonicalHeaderKey("Range"))
+	if len(rng) == 0 {
+		return upload, nil
+	}
+	parts := strings.SplitN(rng, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid range: %s", rng)
+	}
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range: %s", rng)
+	}
+	upload.Offset = end + 1
+
+	return upload, nil
+}
+
+// resolveLocation converts the location which may be relative to an absolute URL
+func (r *Repository) resolveLocation(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	return r.Endpoint.ResolveReference(u).String()
+}
+
 // DeleteBlob ...
 func (r *Repository) DeleteBlob(digest string) error {
 	req, err := http.NewRequest("DELETE", buildBlobURL(r.Endpoint.String(), r.Name, digest), nil)
@@ -481,6 +724,17 @@ func buildInitiateBlobUploadURL(endpoint, repoName string) string {
 	return fmt.Sprintf("%s/v2/%s/blobs/uploads/", endpoint, repoName)
 }
 
+func buildMountBlobURL(endpoint, repoName, digest, from string) string {
+	return fmt.Sprintf("%s/v2/%s/blobs/uploads/?mount=%s&from=%s", endpoint, repoName, digest, url.QueryEscape(from))
+}
+
+func buildBlobUploadURL(endpoint, repoName, uuid string) string {
+	return fmt.Sprintf("%s/v2/%s/blobs/uploads/%s", endpoint, repoName, uuid)
+}
+
 func buildMonolithicBlobUploadURL(location, digest string) string {
-	return fmt.Sprintf("%s&digest=%s", location, digest)
+	if strings.Contains(location, "?") {
+		return fmt.Sprintf("%s&digest=%s", location, digest)
+	}
+	return fmt.Sprintf("%s?digest=%s", location, digest)
 }
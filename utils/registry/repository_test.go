@@ -18,9 +18,11 @@ package registry
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -40,6 +42,13 @@ var (
 	registryServer   *httptest.Server
 	tokenServer      *httptest.Server
 	repositoryClient *Repository
+
+	blobDigest   = "sha256:0123456789abcdef"
+	blobContent  = "0123456789abcdefghijklmnopqrstuvwxyz"
+	uploadUUID   = "upload-uuid"
+	uploadedData []byte
+	uploadDone   bool
+	mountFrom    = "library/base"
 )
 
 type tagResp struct {
@@ -63,6 +72,8 @@ func initRegistryServer() *httptest.Server {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v2/", servePing)
 	mux.HandleFunc(fmt.Sprintf("/v2/%s/tags/list", repo), serveTaglisting)
+	mux.HandleFunc(fmt.Sprintf("/v2/%s/blobs/uploads/", repo), serveBlobUpload)
+	mux.HandleFunc(fmt.Sprintf("/v2/%s/blobs/%s", repo, blobDigest), serveBlob)
 
 	return httptest.NewServer(mux)
 }
@@ -89,6 +100,69 @@ func serveTaglisting(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// a simplified upload endpoint which supports only one session
+func serveBlobUpload(w http.ResponseWriter, r *http.Request) {
+	if !isTokenValid(r) {
+		challenge(w)
+		return
+	}
+
+	location := fmt.Sprintf("/v2/%s/blobs/uploads/%s?_state=%d", repo, uploadUUID, len(uploadedData))
+	writeStatus := func(code int) {
+		w.Header().Set("Location", location)
+		w.Header().Set("Docker-Upload-UUID", uploadUUID)
+		w.Header().Set("Range", fmt.Sprintf("0-%d", len(uploadedData)-1))
+		w.WriteHeader(code)
+	}
+
+	switch r.Method {
+	case "POST":
+		if len(r.FormValue("mount")) != 0 {
+			if r.FormValue("mount") == blobDigest && r.FormValue("from") == mountFrom {
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+			writeStatus(http.StatusAccepted)
+			return
+		}
+		uploadedData = nil
+		uploadDone = false
+		writeStatus(http.StatusAccepted)
+	case "GET":
+		writeStatus(http.StatusNoContent)
+	case "PATCH":
+		if r.FormValue("_state") != strconv.Itoa(len(uploadedData)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Content-Range") != fmt.Sprintf("%d-%d", len(uploadedData), len(uploadedData)+int(r.ContentLength)-1) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		uploadedData = append(uploadedData, b...)
+		location = fmt.Sprintf("/v2/%s/blobs/uploads/%s?_state=%d", repo, uploadUUID, len(uploadedData))
+		writeStatus(http.StatusAccepted)
+	case "DELETE":
+		w.WriteHeader(http.StatusNoContent)
+	case "PUT":
+		if r.FormValue("digest") != blobDigest || string(uploadedData) != blobContent {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		uploadDone = true
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func serveBlob(w http.ResponseWriter, r *http.Request) {
+	if !isTokenValid(r) {
+		challenge(w)
+		return
+	}
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(blobContent))
+}
+
 func isTokenValid(r *http.Request) bool {
 	valid := false
 	auth := r.Header.Get(http.CanonicalHeaderKey("Authorization"))
@@ -176,6 +250,76 @@ func TestListTagWithInvalidCredential(t *testing.T) {
 	}
 }
 
+func TestPushBlobChunkedAndResume(t *testing.T) {
+	client, err := newRepositoryClient(registryServer.URL, true, credential,
+		repo, "repository", repo, "pull", "push", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload, err := client.InitiateBlobUpload()
+	if err != nil {
+		t.Fatalf("failed to initiate upload: %v", err)
+	}
+
+	data := strings.NewReader(blobContent)
+	if err = client.PushBlobChunk(upload, 10, data); err != nil {
+		t.Fatalf("failed to push chunk: %v", err)
+	}
+	if upload.Offset != 10 {
+		t.Fatalf("unexpected offset: %d, expected: 10", upload.Offset)
+	}
+
+	// resume the session by UUID as if the upload was interrupted
+	upload, err = client.ResumeBlobUpload(uploadUUID)
+	if err != nil {
+		t.Fatalf("failed to resume upload: %v", err)
+	}
+	if upload.Offset != 10 {
+		t.Fatalf("unexpected offset after resuming: %d, expected: 10", upload.Offset)
+	}
+
+	size, rest, err := client.PullBlobFrom(blobDigest, upload.Offset)
+	if err != nil {
+		t.Fatalf("failed to pull blob from offset %d: %v", upload.Offset, err)
+	}
+	defer rest.Close()
+	if size != int64(len(blobContent))-upload.Offset {
+		t.Fatalf("unexpected size of remaining content: %d", size)
+	}
+
+	if err = client.PushBlobChunked(upload, blobDigest, size, 8, rest); err != nil {
+		t.Fatalf("failed to push remaining chunks: %v", err)
+	}
+	if !uploadDone {
+		t.Errorf("the upload is not completed, uploaded: %s", string(uploadedData))
+	}
+}
+
+func TestMountBlob(t *testing.T) {
+	client, err := newRepositoryClient(registryServer.URL, true, credential,
+		repo, "repository", repo, "pull", "push", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mounted, err := client.MountBlob(blobDigest, mountFrom)
+	if err != nil {
+		t.Fatalf("failed to mount blob: %v", err)
+	}
+	if !mounted {
+		t.Errorf("blob %s should be mounted from %s", blobDigest, mountFrom)
+	}
+
+	mounted, err = client.MountBlob(blobDigest, "library/other")
+	if err != nil {
+		t.Fatalf("failed to mount blob: %v", err)
+	}
+	if mounted {
+		t.Errorf("blob %s should not be mounted from library/other", blobDigest)
+	}
+}
+
 func newRepositoryClient(endpoint string, insecure bool, credential auth.Credential, repository, scopeType, scopeName string,
 	scopeActions ...string) (*Repository, error) {
 
@@ -16,6 +16,7 @@
 package registry
 
 import (
+	"crypto/tls"
 	"net/http"
 
 	"github.com/vmware/harbor/utils/log"
@@ -27,6 +28,17 @@ type Transport struct {
 	modifiers []Modifier
 }
 
+// GetHTTPTransport returns the transport which the clients of registry are built on, it honors
+// the proxy settings in the environment and skips the verification of certificate if insecure is true.
+func GetHTTPTransport(insecure bool) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: insecure,
+		},
+	}
+}
+
 // NewTransport ...
 func NewTransport(transport http.RoundTripper, modifiers ...Modifier) *Transport {
 	return &Transport{